@@ -0,0 +1,64 @@
+// Command icdimport builds the local ICD-11 index served when ICD_SOURCE is
+// set to local, from the linearization tabulation of a WHO ICD-11 release.
+//
+// The tabulations carry no definitions. To serve them, run icdimport with
+// -export-definitions where the WHO API can be reached, with the same
+// ICD_CLIENTID and ICD_CLIENTSECRET as the server, and pass the file it
+// writes to -definitions of the import.
+package main
+
+import (
+	"backend/internal/repository"
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	tabulation := flag.String("tabulation", "", "Path to the linearization tabulation, e.g. LinearizationMiniOutput-MMS-en.txt")
+	definitions := flag.String("definitions", "", "Path to the definitions written by -export-definitions, if any")
+	exportDefinitions := flag.String("export-definitions", "", "Write the definitions of the release to this path from the WHO API instead of importing")
+	index := flag.String("index", "icd.bleve", "Path of the index to create")
+	release := flag.String("release", repository.DefaultICDEdition.Release, "WHO release the tabulation belongs to, e.g. 2025-01")
+	linearization := flag.String("linearization", repository.DefaultICDEdition.Linearization, "Linearization of the tabulation, e.g. mms")
+	language := flag.String("language", repository.DefaultICDEdition.Language, "Language of the tabulation, e.g. en")
+	flag.Parse()
+
+	edition := repository.ICDEdition{
+		Release:       *release,
+		Linearization: *linearization,
+		Language:      *language,
+	}
+
+	if *exportDefinitions != "" {
+		whoEndpoint := repository.WHOEndpoint{
+			APIURL:       os.Getenv("ICD_API_URL"),
+			TokenURL:     os.Getenv("ICD_TOKEN_URL"),
+			ClientID:     os.Getenv("ICD_CLIENTID"),
+			ClientSecret: os.Getenv("ICD_CLIENTSECRET"),
+		}
+
+		icdRepository, err := repository.NewICDRepository(&http.Client{}, whoEndpoint, repository.DefaultWHOClientOptions, nil, edition)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		count, err := repository.ExportICDDefinitions(context.Background(), icdRepository, *exportDefinitions)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Printf("Successfully exported %d ICD-11 definitions", count)
+		return
+	}
+
+	if *tabulation == "" {
+		log.Fatalln("-tabulation is required")
+	}
+
+	if err := repository.ImportICDRelease(*tabulation, *definitions, *index, edition); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reviewerKey is the key of the authenticated reviewer in the gin context.
+const reviewerKey = "reviewer"
+
+// Reviewers are the terminologists allowed to review mappings, by the bearer
+// token each one authenticates with.
+type Reviewers map[string]string
+
+// ParseReviewers parses reviewers written as comma separated name:token
+// pairs, such as "asha:s3cret,ravi:t0ken".
+func ParseReviewers(value string) (Reviewers, error) {
+	reviewers := make(Reviewers)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, token, ok := strings.Cut(pair, ":")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, errors.New("reviewers must be written as name:token pairs")
+		}
+		if _, ok := reviewers[token]; ok {
+			return nil, errors.New("reviewers must not share a token")
+		}
+		reviewers[token] = name
+	}
+
+	return reviewers, nil
+}
+
+// RequireReviewer lets only the requests bearing the token of one of
+// reviewers through, and records who they are for reviewerName. With no
+// reviewers, every request is turned away.
+func RequireReviewer(reviewers Reviewers) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			ctx.Header("WWW-Authenticate", `Bearer realm="mapping review"`)
+			respondOutcome(ctx, http.StatusUnauthorized, "login", "a reviewer token is required")
+			return
+		}
+
+		// Every token is compared, in constant time, so that the time taken
+		// tells nothing about them.
+		var name string
+		for candidate, reviewer := range reviewers {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+				name = reviewer
+			}
+		}
+		if name == "" {
+			ctx.Header("WWW-Authenticate", `Bearer realm="mapping review", error="invalid_token"`)
+			respondOutcome(ctx, http.StatusUnauthorized, "login", "unknown reviewer token")
+			return
+		}
+
+		ctx.Set(reviewerKey, name)
+		ctx.Next()
+	}
+}
+
+// reviewerName returns the reviewer authenticated by RequireReviewer.
+func reviewerName(ctx *gin.Context) string {
+	return ctx.GetString(reviewerKey)
+}
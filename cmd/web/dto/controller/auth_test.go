@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseReviewers(t *testing.T) {
+	reviewers, err := ParseReviewers(" asha:s3cret, ravi:t0ken ,")
+	if err != nil {
+		t.Fatalf("ParseReviewers: %v", err)
+	}
+	if len(reviewers) != 2 || reviewers["s3cret"] != "asha" || reviewers["t0ken"] != "ravi" {
+		t.Errorf("ParseReviewers returned %v", reviewers)
+	}
+
+	for _, value := range []string{"asha", "asha:", ":s3cret", "asha:s3cret,ravi:s3cret"} {
+		if _, err := ParseReviewers(value); err == nil {
+			t.Errorf("ParseReviewers(%q) succeeded", value)
+		}
+	}
+}
+
+func TestRequireReviewer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/review", RequireReviewer(Reviewers{"s3cret": "asha"}), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, reviewerName(ctx))
+	})
+
+	tests := []struct {
+		authorization string
+		status        int
+		reviewer      string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"s3cret", http.StatusUnauthorized, ""},
+		{"Bearer wrong", http.StatusUnauthorized, ""},
+		{"Bearer s3cret", http.StatusOK, "asha"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/review", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("Authorization %q got %d, want %d", test.authorization, rec.Code, test.status)
+		}
+		if test.status == http.StatusOK && rec.Body.String() != test.reviewer {
+			t.Errorf("Authorization %q reviewed as %q, want %q", test.authorization, rec.Body.String(), test.reviewer)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"backend/cmd/web/dto"
 	"backend/internal/service"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,22 +24,79 @@ type autocompleteController struct {
 // @Summary		Retrive matches
 // @Description	Retrieves matches by combining results from ICD and NAMASTE repositories
 // @Produce		json
-// @Param		query query string true "Search query"
+// @Param		query query string true "Search query, or an ICD-11 cluster such as SA80/SF57 to find the NAMASTE diseases mapped to it"
+// @Param		module query string false "ICD-11 module to match: tm2 or biomedicine. Both are matched for dual coding when empty"
+// @Param		lang query string false "Language of the query, such as sa, ta or ur for the native NAMASTE terms. Guessed from the script of the query when empty"
+// @Param		minConfidence query number false "Leave out the pairs with a lower confidence, from 0 to 1. Curated pairs have a confidence of 1"
 // @Success		200		{object}	[]dto.ValueSet
-// @Failure		500		{object}	dto.Error
+// @Header		200		{string}	Warning	"Set when a source timed out and the matches may be incomplete"
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Failure		503		{object}	dto.OperationOutcome
 // @Router			/autocomplete [get]
 func (a *autocompleteController) Find(ctx *gin.Context) {
 	query := ctx.Query("query")
 	query = strings.ToLower(query)
 
-	resp, err := a.service.Find(ctx.Request.Context(), query)
+	minConfidence := 0.0
+	if value := ctx.Query("minConfidence"); value != "" {
+		var err error
+		minConfidence, err = strconv.ParseFloat(value, 64)
+		if err != nil || minConfidence < 0 || minConfidence > 1 {
+			respondInvalid(ctx, "minConfidence must be a number from 0 to 1")
+			return
+		}
+	}
+
+	resp, err := a.service.Find(ctx.Request.Context(), query, service.FindOptions{
+		Module:        ctx.Query("module"),
+		Language:      ctx.Query("lang"),
+		MinConfidence: minConfidence,
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.Error{Error: err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	valueSets := make([]dto.ValueSet, 0)
 	for _, disease := range resp.Diseases {
+		icdSystem := service.ICDSystem
+		if disease.Module == service.ModuleTM2 {
+			icdSystem = service.ICDTM2System
+		}
+
+		namaste := dto.Contain{
+			System:  "https://backend-kl02.onrender.com/api/v1/codesystem/namaste",
+			Code:    disease.Namaste.ID,
+			Display: disease.Namaste.Name,
+			Extension: []dto.Extension{
+				{URL: service.SourceSystemExtension, ValueString: "NAMASTE"},
+			},
+		}
+		icd := dto.Contain{
+			System:  icdSystem,
+			Code:    disease.ICD.ID,
+			Display: disease.ICD.Name,
+			Extension: []dto.Extension{
+				{URL: service.SourceSystemExtension, ValueString: "ICD"},
+			},
+		}
+
+		// A disease that could not be paired is returned on its own, and
+		// without a pairing to rate.
+		var contains []dto.Contain
+		switch {
+		case disease.Namaste.ID == "":
+			contains = []dto.Contain{icd}
+		case disease.ICD.ID == "":
+			contains = []dto.Contain{namaste}
+		default:
+			icd.Extension = append(icd.Extension, service.PairingExtensions(disease)...)
+			contains = []dto.Contain{namaste, icd}
+		}
+
+		total := len(contains)
 		valueSets = append(valueSets, dto.ValueSet{
 			ResourceType: "ValueSet",
 			ID:           "autocomplete-results",
@@ -46,33 +104,23 @@ func (a *autocompleteController) Find(ctx *gin.Context) {
 			Expansion: dto.Expansion{
 				Identifier: "https://backend-kl02.onrender.com/api/v1/autocomplete",
 				Timestamp:  time.Now(),
-				Total:      2,
+				Total:      &total,
 				Offset:     0,
-				Contains: []dto.Contain{
-					{
-						System:  "https://backend-kl02.onrender.com/api/v1/codesystem/namaste",
-						Code:    disease.Namaste.ID,
-						Display: disease.Namaste.Name,
-						Extension: dto.Extension{
-							URL:         "https://backend-kl02.onrender.com/api/v1/structuredefinition/sourceSystem",
-							ValueString: "NAMASTE",
-						},
-					},
-					{
-						System:  "https://backend-kl02.onrender.com/api/v1/codesystem/icd",
-						Code:    disease.ICD.ID,
-						Display: disease.ICD.Name,
-						Extension: dto.Extension{
-							URL:         "https://backend-kl02.onrender.com/api/v1/structuredefinition/sourceSystem",
-							ValueString: "ICD",
-						},
-					},
-				},
+				Contains:   contains,
 			},
 		})
 	}
 
+	// Incomplete matches are flagged, and aborting keeps them out of the
+	// page cache so that the next request tries the sources again.
+	for _, warning := range resp.Warnings {
+		ctx.Writer.Header().Add("Warning", "199 - "+strconv.Quote(warning))
+	}
+
 	ctx.JSON(http.StatusOK, valueSets)
+	if len(resp.Warnings) > 0 {
+		ctx.Abort()
+	}
 }
 
 func NewAutocompleteController(service service.AutoCompleteService) AutocompleteController {
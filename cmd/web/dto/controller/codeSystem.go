@@ -1,11 +1,9 @@
 package controller
 
 import (
-	"backend/cmd/web/dto"
 	"backend/internal/service"
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +11,9 @@ import (
 type CodeSystemController interface {
 	ListNamaste(ctx *gin.Context)
 	ListICD(ctx *gin.Context)
+	ListTM2(ctx *gin.Context)
+	Lookup(ctx *gin.Context)
+	ValidateCode(ctx *gin.Context)
 }
 
 type codeSystemController struct {
@@ -22,31 +23,52 @@ type codeSystemController struct {
 // @Summary		List all ICD codes
 // @Tags Code System
 // @Param		size query int false "Number of codes you want"
+// @Param		version query string false "ICD-11 release, e.g. 2025-01; defaults to the configured release"
+// @Param		displayLanguage query string false "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation"
 // @Produce		json
 // @Success		200		{object}	dto.CodeSystem
-// @Failure		500		{object}	dto.Error
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
 // @Router			/codesystem/icd [get]
 func (c *codeSystemController) ListICD(ctx *gin.Context) {
-	var size int
-	sizeQuery := ctx.Query("size")
-	if sizeQuery == "" {
-		size = 5000
-	} else {
-		var err error
-		size, err = strconv.Atoi(sizeQuery)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, dto.Error{Error: fmt.Sprintf("unable to parse size: %w", err)})
-			return
-		}
+	size, ok := parseSize(ctx)
+	if !ok {
+		return
 	}
 
 	// TODO: Stop hard coding this in future
 	url := "https://backend-kl02.onrender.com/api/v1/codesystem/icd"
-	codeSystem, err := c.codeSystemService.ListICD(size, url)
+	codeSystem, err := c.codeSystemService.ListICD(ctx.Request.Context(), size, url, versionOptions(ctx.Query))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, codeSystem)
+}
+
+// @Summary		List all ICD-11 TM2 codes
+// @Description	Lists the ICD-11 Traditional Medicine Module 2 codes, the chapter NAMASTE codes map to most naturally
+// @Tags Code System
+// @Param		size query int false "Number of codes you want"
+// @Param		version query string false "ICD-11 release, e.g. 2025-01; defaults to the configured release"
+// @Param		displayLanguage query string false "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation"
+// @Produce		json
+// @Success		200		{object}	dto.CodeSystem
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Router			/codesystem/icd-tm2 [get]
+func (c *codeSystemController) ListTM2(ctx *gin.Context) {
+	size, ok := parseSize(ctx)
+	if !ok {
+		return
+	}
+
+	codeSystem, err := c.codeSystemService.ListTM2(ctx.Request.Context(), size, service.ICDTM2System, versionOptions(ctx.Query))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.Error{
-			Error: err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
@@ -58,35 +80,103 @@ func (c *codeSystemController) ListICD(ctx *gin.Context) {
 // @Param		size query int false "Number of codes you want"
 // @Produce		json
 // @Success		200		{object}	dto.CodeSystem
-// @Failure		500		{object}	dto.Error
+// @Failure		500		{object}	dto.OperationOutcome
 // @Router			/codesystem/namaste [get]
 func (c *codeSystemController) ListNamaste(ctx *gin.Context) {
-	var size int
-	sizeQuery := ctx.Query("size")
-	if sizeQuery == "" {
-		size = 5000
-	} else {
-		var err error
-		size, err = strconv.Atoi(sizeQuery)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, dto.Error{Error: fmt.Sprintf("unable to parse size: %w", err)})
-			return
-		}
+	size, ok := parseSize(ctx)
+	if !ok {
+		return
 	}
 
 	// TODO: Stop hard coding this in future
 	url := "https://backend-kl02.onrender.com/api/v1/codesystem/namaste"
-	codeSystem, err := c.codeSystemService.ListNamaste(size, url)
+	codeSystem, err := c.codeSystemService.ListNamaste(ctx.Request.Context(), size, url)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.Error{
-			Error: err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, codeSystem)
 }
 
+// @Summary		Look up a code
+// @Description	Returns the display, definition, native designations and properties of a single NAMASTE or ICD code. POST accepts the same inputs as a FHIR Parameters resource.
+// @Tags Code System
+// @Param		system query string true "Code system URL"
+// @Param		code query string true "Code to look up"
+// @Param		version query string false "ICD-11 release, e.g. 2025-01; defaults to the configured release"
+// @Param		displayLanguage query string false "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation"
+// @Produce		json
+// @Success		200		{object}	dto.Parameters
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		404		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Router			/codesystem/$lookup [get]
+// @Router			/codesystem/$lookup [post]
+func (c *codeSystemController) Lookup(ctx *gin.Context) {
+	params, err := operationParameters(ctx)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse parameters: %v", err))
+		return
+	}
+
+	system := params.Value("system")
+	code := params.Value("code")
+
+	if system == "" || code == "" {
+		respondInvalid(ctx, "system and code are required")
+		return
+	}
+
+	parameters, err := c.codeSystemService.Lookup(ctx.Request.Context(), system, code, versionOptions(params.Value))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, parameters)
+}
+
+// @Summary		Validate a code
+// @Description	Checks that a code, and optionally its display, belongs to a NAMASTE or ICD code system. POST accepts the same inputs as a FHIR Parameters resource.
+// @Tags Code System
+// @Param		system query string true "Code system URL"
+// @Param		code query string true "Code to validate. ICD-11 codes may be postcoordinated clusters, e.g. 1A00&XS25 or SA80/SF57"
+// @Param		display query string false "Display to check against the code"
+// @Param		version query string false "ICD-11 release, e.g. 2025-01; defaults to the configured release"
+// @Param		displayLanguage query string false "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation"
+// @Produce		json
+// @Success		200		{object}	dto.Parameters
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Router			/codesystem/$validate-code [get]
+// @Router			/codesystem/$validate-code [post]
+func (c *codeSystemController) ValidateCode(ctx *gin.Context) {
+	params, err := operationParameters(ctx)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse parameters: %v", err))
+		return
+	}
+
+	system := params.Value("system")
+	code := params.Value("code")
+
+	if system == "" || code == "" {
+		respondInvalid(ctx, "system and code are required")
+		return
+	}
+
+	parameters, err := c.codeSystemService.ValidateCode(ctx.Request.Context(), system, code, params.Value("display"), versionOptions(params.Value))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, parameters)
+}
+
 func NewCodeSystemController(codeSystemService service.CodeSystemService) CodeSystemController {
 	return &codeSystemController{
 		codeSystemService: codeSystemService,
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConceptMapController interface {
+	Get(ctx *gin.Context)
+	Translate(ctx *gin.Context)
+}
+
+type conceptMapController struct {
+	conceptMapService service.ConceptMapService
+}
+
+// @Summary		Get the NAMASTE to ICD-11 concept map
+// @Description	Returns every stored NAMASTE to ICD-11 mapping as a FHIR ConceptMap
+// @Tags Concept Map
+// @Produce		json
+// @Success		200		{object}	dto.ConceptMap
+// @Failure		500		{object}	dto.OperationOutcome
+// @Router			/conceptmap [get]
+func (c *conceptMapController) Get(ctx *gin.Context) {
+	conceptMap, err := c.conceptMapService.Get()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, conceptMap)
+}
+
+// @Summary		Translate a code
+// @Description	Translates a NAMASTE code to ICD-11, or an ICD-11 code to NAMASTE, using the stored concept map. POST accepts the same inputs as a FHIR Parameters resource.
+// @Tags Concept Map
+// @Param		system query string true "Code system URL of the code"
+// @Param		code query string true "Code to translate"
+// @Param		target query string false "Code system URL to translate into"
+// @Produce		json
+// @Success		200		{object}	dto.Parameters
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Router			/conceptmap/$translate [get]
+// @Router			/conceptmap/$translate [post]
+func (c *conceptMapController) Translate(ctx *gin.Context) {
+	params, err := operationParameters(ctx)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse parameters: %v", err))
+		return
+	}
+
+	system := params.Value("system")
+	code := params.Value("code")
+
+	if system == "" || code == "" {
+		respondInvalid(ctx, "system and code are required")
+		return
+	}
+
+	parameters, err := c.conceptMapService.Translate(system, code, params.Value("target"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, parameters)
+}
+
+func NewConceptMapController(conceptMapService service.ConceptMapService) ConceptMapController {
+	return &conceptMapController{
+		conceptMapService: conceptMapService,
+	}
+}
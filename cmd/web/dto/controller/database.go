@@ -17,14 +17,14 @@ type databaseController struct {
 }
 
 // @Summary		Syncs databases
-// @Description	Updates the database index with the NAMASTE CSV files' data
+// @Description	Rebuilds the NAMASTE index from the CSV files. Searches keep using the previous index until the new one is complete
 // @Produce		json
 // @Success		200		{object}	dto.Message
-// @Failure		500		{object}	dto.Error
+// @Failure		500		{object}	dto.OperationOutcome
 // @Router			/sync [get]
 func (d *databaseController) Sync(ctx *gin.Context) {
 	if err := d.service.Update(); err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.Error{Error: err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
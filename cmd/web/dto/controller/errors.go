@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError reports a service error as an OperationOutcome, with the HTTP
+// status and issue code that match its kind.
+func respondError(ctx *gin.Context, err error) {
+	switch service.KindOf(err) {
+	case service.KindInvalid:
+		respondOutcome(ctx, http.StatusBadRequest, "invalid", err.Error())
+	case service.KindNotFound:
+		respondOutcome(ctx, http.StatusNotFound, "not-found", err.Error())
+	case service.KindConflict:
+		respondOutcome(ctx, http.StatusConflict, "conflict", err.Error())
+	case service.KindUpstream:
+		respondOutcome(ctx, http.StatusBadGateway, "exception", err.Error())
+	case service.KindUnavailable:
+		respondOutcome(ctx, http.StatusServiceUnavailable, "transient", err.Error())
+	default:
+		respondOutcome(ctx, http.StatusInternalServerError, "exception", err.Error())
+	}
+}
+
+// respondInvalid reports a request the controller could not parse.
+func respondInvalid(ctx *gin.Context, diagnostics string) {
+	respondOutcome(ctx, http.StatusBadRequest, "invalid", diagnostics)
+}
+
+func respondOutcome(ctx *gin.Context, status int, code string, diagnostics string) {
+	ctx.AbortWithStatusJSON(status, dto.OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue: []dto.Issue{
+			{
+				Severity:    "error",
+				Code:        code,
+				Diagnostics: diagnostics,
+			},
+		},
+	})
+}
+
+// NotFound answers requests for routes that do not exist.
+func NotFound(ctx *gin.Context) {
+	respondOutcome(ctx, http.StatusNotFound, "not-found", "no route for "+ctx.Request.Method+" "+ctx.Request.URL.Path)
+}
+
+// LimitReached answers requests rejected by the rate limiter.
+func LimitReached(ctx *gin.Context) {
+	respondOutcome(ctx, http.StatusTooManyRequests, "throttled", "rate limit exceeded")
+}
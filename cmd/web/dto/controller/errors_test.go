@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/repository"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("lookup of 1A09: %w", repository.ErrNotFound), http.StatusNotFound, "not-found"},
+		{"mapping not found", repository.ErrMappingNotFound, http.StatusNotFound, "not-found"},
+		{"mapping conflict", fmt.Errorf("%w: SA81", repository.ErrMappingConflict), http.StatusConflict, "conflict"},
+		{"unknown system", fmt.Errorf("%w: http://snomed.info/sct", service.ErrUnknownSystem), http.StatusBadRequest, "invalid"},
+		{"invalid edition", fmt.Errorf("release 1999-01: %w", repository.ErrInvalidEdition), http.StatusBadRequest, "invalid"},
+		{"invalid cluster", fmt.Errorf("%w: \"cold\" is not a stem code", repository.ErrInvalidCluster), http.StatusBadRequest, "invalid"},
+		{"explicit kind", &service.Error{Kind: service.KindInvalid, Err: errors.New("bad paging")}, http.StatusBadRequest, "invalid"},
+		{"upstream", &repository.UpstreamError{Service: "WHO ICD API", Err: errors.New("status 500")}, http.StatusBadGateway, "exception"},
+		{"circuit open", fmt.Errorf("search: %w", repository.ErrCircuitOpen), http.StatusServiceUnavailable, "transient"},
+		{"internal", errors.New("disk full"), http.StatusInternalServerError, "exception"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rec)
+
+			respondError(ctx, test.err)
+
+			var outcome dto.OperationOutcome
+			if err := json.Unmarshal(rec.Body.Bytes(), &outcome); err != nil {
+				t.Fatalf("body %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != test.status {
+				t.Errorf("status = %d, want %d", rec.Code, test.status)
+			}
+			if outcome.ResourceType != "OperationOutcome" || len(outcome.Issue) != 1 {
+				t.Fatalf("outcome = %+v", outcome)
+			}
+			issue := outcome.Issue[0]
+			if issue.Severity != "error" || issue.Code != test.code || issue.Diagnostics != test.err.Error() {
+				t.Errorf("issue = %+v, want code %s and diagnostics %q", issue, test.code, test.err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"backend/internal/repository"
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MappingController interface {
+	List(ctx *gin.Context)
+	Accept(ctx *gin.Context)
+	Reject(ctx *gin.Context)
+	Edit(ctx *gin.Context)
+}
+
+type mappingController struct {
+	mappingService service.MappingService
+}
+
+// @Summary		List mappings
+// @Description	Lists the stored NAMASTE to ICD-11 mappings, optionally only those with the given review status
+// @Tags Mapping
+// @Param		status query string false "proposed, accepted or rejected"
+// @Produce		json
+// @Success		200		{object}	[]repository.Mapping
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Router			/mapping [get]
+func (m *mappingController) List(ctx *gin.Context) {
+	mappings, err := m.mappingService.List(ctx.Query("status"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, mappings)
+}
+
+// @Summary		Accept a mapping
+// @Tags Mapping
+// @Param		id path int true "Mapping id"
+// @Security	ReviewerToken
+// @Param		review body service.MappingReview true "Comment on the review"
+// @Produce		json
+// @Success		200		{object}	repository.Mapping
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		401		{object}	dto.OperationOutcome
+// @Failure		404		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Router			/mapping/{id}/accept [post]
+func (m *mappingController) Accept(ctx *gin.Context) {
+	m.review(ctx, m.mappingService.Accept)
+}
+
+// @Summary		Reject a mapping
+// @Tags Mapping
+// @Param		id path int true "Mapping id"
+// @Security	ReviewerToken
+// @Param		review body service.MappingReview true "Comment on the review"
+// @Produce		json
+// @Success		200		{object}	repository.Mapping
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		401		{object}	dto.OperationOutcome
+// @Failure		404		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Router			/mapping/{id}/reject [post]
+func (m *mappingController) Reject(ctx *gin.Context) {
+	m.review(ctx, m.mappingService.Reject)
+}
+
+// @Summary		Edit a mapping
+// @Description	Replaces the ICD-11 side of a mapping, which accepts it. The code must exist in ICD-11, and its display is looked up. A pair that another mapping already holds is a conflict.
+// @Tags Mapping
+// @Param		id path int true "Mapping id"
+// @Security	ReviewerToken
+// @Param		edit body service.MappingEdit true "New ICD code or cluster, equivalence and comment"
+// @Produce		json
+// @Success		200		{object}	repository.Mapping
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		401		{object}	dto.OperationOutcome
+// @Failure		404		{object}	dto.OperationOutcome
+// @Failure		409		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Router			/mapping/{id} [put]
+func (m *mappingController) Edit(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse id: %v", err))
+		return
+	}
+
+	var edit service.MappingEdit
+	if err := ctx.ShouldBindJSON(&edit); err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse edit: %v", err))
+		return
+	}
+	edit.Reviewer = reviewerName(ctx)
+
+	mapping, err := m.mappingService.Edit(ctx.Request.Context(), id, edit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, mapping)
+}
+
+func (m *mappingController) review(ctx *gin.Context, review func(uint64, service.MappingReview) (*repository.Mapping, error)) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse id: %v", err))
+		return
+	}
+
+	var body service.MappingReview
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse review: %v", err))
+		return
+	}
+	body.Reviewer = reviewerName(ctx)
+
+	mapping, err := review(id, body)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, mapping)
+}
+
+func NewMappingController(mappingService service.MappingService) MappingController {
+	return &mappingController{
+		mappingService: mappingService,
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"backend/internal/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetadataController interface {
+	Metadata(ctx *gin.Context)
+}
+
+type metadataController struct {
+	metadataService service.MetadataService
+	routes          func() gin.RoutesInfo
+	basePath        string
+}
+
+// @Summary		Get the server capabilities
+// @Description	Returns the FHIR CapabilityStatement of the server, or its TerminologyCapabilities with mode=terminology
+// @Tags Metadata
+// @Param		mode query string false "full or terminology"
+// @Produce		json
+// @Success		200		{object}	dto.CapabilityStatement
+// @Router			/metadata [get]
+func (m *metadataController) Metadata(ctx *gin.Context) {
+	// The routes are read on every request rather than at startup so that
+	// routes registered after this controller are included.
+	routes := make([]service.Route, 0)
+	for _, route := range m.routes() {
+		path, ok := strings.CutPrefix(route.Path, m.basePath)
+		if !ok {
+			continue
+		}
+		routes = append(routes, service.Route{Method: route.Method, Path: path})
+	}
+
+	if ctx.Query("mode") == "terminology" {
+		ctx.JSON(http.StatusOK, m.metadataService.TerminologyCapabilities(routes))
+		return
+	}
+
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := ctx.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	ctx.JSON(http.StatusOK, m.metadataService.CapabilityStatement(routes, scheme+"://"+ctx.Request.Host+m.basePath))
+}
+
+func NewMetadataController(metadataService service.MetadataService, routes func() gin.RoutesInfo, basePath string) MetadataController {
+	return &metadataController{
+		metadataService: metadataService,
+		routes:          routes,
+		basePath:        basePath,
+	}
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newMetadataRouter returns a router with the routes of main.go, each
+// answered by a no-op handler but for the metadata.
+func newMetadataRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	const basePath = "/api/v1"
+	noop := func(*gin.Context) {}
+
+	r := gin.New()
+	metadataController := NewMetadataController(service.NewMetadataService("API", "1.0", "test", "2025-01"), r.Routes, basePath)
+
+	r.GET(basePath+"/typeahead", noop)
+
+	apiRoutes := r.Group(basePath)
+	{
+		codeSystemRoutes := apiRoutes.Group("/codesystem")
+		for _, path := range []string{"/namaste", "/icd", "/icd-tm2"} {
+			codeSystemRoutes.GET(path, noop)
+		}
+		for _, path := range []string{"/$lookup", "/$validate-code"} {
+			codeSystemRoutes.GET(path, noop)
+			codeSystemRoutes.POST(path, noop)
+		}
+
+		valueSetRoutes := apiRoutes.Group("/valueset")
+		for _, path := range []string{"/$validate-code", "/$expand"} {
+			valueSetRoutes.GET(path, noop)
+			valueSetRoutes.POST(path, noop)
+		}
+
+		conceptMapRoutes := apiRoutes.Group("/conceptmap")
+		conceptMapRoutes.GET("", noop)
+		conceptMapRoutes.GET("/$translate", noop)
+		conceptMapRoutes.POST("/$translate", noop)
+
+		mappingRoutes := apiRoutes.Group("/mapping")
+		mappingRoutes.GET("", noop)
+		mappingRoutes.PUT("/:id", noop)
+		mappingRoutes.POST("/:id/accept", noop)
+		mappingRoutes.POST("/:id/reject", noop)
+
+		for _, path := range []string{"/sync", "/autocomplete", "/health"} {
+			apiRoutes.GET(path, noop)
+		}
+		apiRoutes.GET("/metadata", metadataController.Metadata)
+	}
+
+	return r
+}
+
+func getMetadata(t *testing.T, r *gin.Engine, target string, body any) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s got %d: %s", target, rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), body); err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+}
+
+func TestMetadataCapabilityStatement(t *testing.T) {
+	var statement dto.CapabilityStatement
+	getMetadata(t, newMetadataRouter(), "/api/v1/metadata", &statement)
+
+	if statement.Implementation.URL != "https://example.com/api/v1" {
+		t.Errorf("implementation URL = %q", statement.Implementation.URL)
+	}
+	if len(statement.Rest) != 1 {
+		t.Fatalf("rest = %+v", statement.Rest)
+	}
+
+	want := map[string]struct {
+		interactions []string
+		operations   []string
+	}{
+		"CodeSystem": {[]string{"read"}, []string{"lookup", "validate-code"}},
+		"ValueSet":   {nil, []string{"validate-code", "expand"}},
+		"ConceptMap": {[]string{"read"}, []string{"translate"}},
+	}
+
+	resources := statement.Rest[0].Resource
+	if len(resources) != len(want) {
+		t.Errorf("CapabilityStatement lists %d resources, want %d: %+v", len(resources), len(want), resources)
+	}
+	for _, resource := range resources {
+		expected, ok := want[resource.Type]
+		if !ok {
+			t.Errorf("CapabilityStatement lists %s, which is not a FHIR resource of the server", resource.Type)
+			continue
+		}
+
+		var interactions, operations []string
+		for _, interaction := range resource.Interaction {
+			interactions = append(interactions, interaction.Code)
+		}
+		for _, operation := range resource.Operation {
+			operations = append(operations, operation.Name)
+			if operation.Definition != "http://hl7.org/fhir/OperationDefinition/"+resource.Type+"-"+operation.Name {
+				t.Errorf("%s $%s is defined by %s", resource.Type, operation.Name, operation.Definition)
+			}
+		}
+		if !slices.Equal(interactions, expected.interactions) || !slices.Equal(operations, expected.operations) {
+			t.Errorf("%s has interactions %v and operations %v, want %v and %v", resource.Type, interactions, operations, expected.interactions, expected.operations)
+		}
+	}
+}
+
+func TestMetadataTerminologyCapabilities(t *testing.T) {
+	var capabilities dto.TerminologyCapabilities
+	getMetadata(t, newMetadataRouter(), "/api/v1/metadata?mode=terminology", &capabilities)
+
+	if capabilities.Expansion == nil || !capabilities.Expansion.Paging || capabilities.ValidateCode == nil || capabilities.Translation == nil {
+		t.Errorf("TerminologyCapabilities = %+v, want expansion, validateCode and translation", capabilities)
+	}
+
+	var systems []string
+	for _, codeSystem := range capabilities.CodeSystem {
+		systems = append(systems, codeSystem.URI)
+	}
+	for _, system := range []string{service.ICDSystem, service.ICDTM2System, service.NamasteSystem, service.NamasteSystem + "/siddha"} {
+		if !slices.Contains(systems, system) {
+			t.Errorf("TerminologyCapabilities lists %v, want %s", systems, system)
+		}
+	}
+}
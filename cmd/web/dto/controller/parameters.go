@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// operationParameters reads the inputs of a FHIR operation, which come from
+// the query string on GET and from a Parameters resource on POST.
+func operationParameters(ctx *gin.Context) (dto.Parameters, error) {
+	var params dto.Parameters
+
+	if ctx.Request.Method == http.MethodPost {
+		err := ctx.ShouldBindJSON(&params)
+		return params, err
+	}
+
+	params.ResourceType = "Parameters"
+	for name, values := range ctx.Request.URL.Query() {
+		for _, value := range values {
+			params.Parameter = append(params.Parameter, dto.Parameter{Name: name, ValueString: value})
+		}
+	}
+
+	return params, nil
+}
+
+// versionOptions reads the version and displayLanguage inputs through value,
+// which is a query or a Parameters lookup.
+func versionOptions(value func(name string) string) service.VersionOptions {
+	return service.VersionOptions{
+		Version:         value("version"),
+		DisplayLanguage: value("displayLanguage"),
+	}
+}
+
+// parseSize reads the size query of the code system listings, which is 5000
+// when not given. An invalid size is reported to the client, and ok is false.
+func parseSize(ctx *gin.Context) (size int, ok bool) {
+	sizeQuery := ctx.Query("size")
+	if sizeQuery == "" {
+		return 5000, true
+	}
+
+	size, err := strconv.Atoi(sizeQuery)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse size: %v", err))
+		return 0, false
+	}
+	if size < 0 {
+		respondInvalid(ctx, fmt.Sprintf("invalid size: %d", size))
+		return 0, false
+	}
+
+	return size, true
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query string
+		size  int
+		ok    bool
+	}{
+		{"", 5000, true},
+		{"?size=0", 0, true},
+		{"?size=20", 20, true},
+		{"?size=-1", 0, false},
+		{"?size=ten", 0, false},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/codesystem/icd"+test.query, nil)
+
+		size, ok := parseSize(ctx)
+		if size != test.size || ok != test.ok {
+			t.Errorf("parseSize(%q) = %d, %t, want %d, %t", test.query, size, ok, test.size, test.ok)
+		}
+		if !ok && rec.Code != http.StatusBadRequest {
+			t.Errorf("parseSize(%q) responded %d, want 400", test.query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of suggestions when the client does not ask for a number, and the
+// most a client can ask for.
+const (
+	defaultSuggestCount = 10
+	maxSuggestCount     = 50
+)
+
+type TypeaheadController interface {
+	Suggest(ctx *gin.Context)
+}
+
+type typeaheadController struct {
+	typeaheadService service.TypeaheadService
+}
+
+// @Summary		Complete a partial NAMASTE term
+// @Description	Suggests NAMASTE codes while a term is being typed, tolerating unfinished words and typos. Exact words rank above prefixes, which rank above near misses. Unlike autocomplete, no ICD-11 pairing is done, so it is fast enough to call on every keystroke.
+// @Param		query query string true "Partial term"
+// @Param		system query string false "NAMASTE code system or branch system URL"
+// @Param		lang query string false "Language of the query, such as sa, ta or ur. Guessed from the script of the query when empty"
+// @Param		count query int false "Maximum number of suggestions"
+// @Produce		json
+// @Success		200		{object}	dto.ValueSet
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Router			/typeahead [get]
+func (t *typeaheadController) Suggest(ctx *gin.Context) {
+	input := ctx.Query("query")
+	if input == "" {
+		respondInvalid(ctx, "query is required")
+		return
+	}
+
+	count := defaultSuggestCount
+	if countQuery := ctx.Query("count"); countQuery != "" {
+		var err error
+		count, err = strconv.Atoi(countQuery)
+		if err != nil || count < 0 {
+			respondInvalid(ctx, fmt.Sprintf("invalid count: %s", countQuery))
+			return
+		}
+	}
+
+	valueSet, err := t.typeaheadService.Suggest(ctx.Request.Context(), input, service.SuggestOptions{
+		System:   ctx.Query("system"),
+		Language: ctx.Query("lang"),
+		Count:    min(count, maxSuggestCount),
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, valueSet)
+}
+
+func NewTypeaheadController(typeaheadService service.TypeaheadService) TypeaheadController {
+	return &typeaheadController{
+		typeaheadService: typeaheadService,
+	}
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size of an expansion when the client does not ask for one, and the
+// largest page a client can ask for.
+const (
+	defaultExpandCount = 100
+	maxExpandCount     = 1000
+)
+
+type ValueSetController interface {
+	ValidateCode(ctx *gin.Context)
+	Expand(ctx *gin.Context)
+}
+
+type valueSetController struct {
+	valueSetService service.ValueSetService
+}
+
+// @Summary		Validate a code against a value set
+// @Description	Checks that a code, and optionally its display, is in a value set. The implicit value sets of the code systems are addressed as the code system URL followed by ?fhir_vs. POST accepts the same inputs as a FHIR Parameters resource.
+// @Tags Value Set
+// @Param		url query string false "Value set URL"
+// @Param		system query string false "Code system URL, required without url"
+// @Param		code query string true "Code to validate"
+// @Param		display query string false "Display to check against the code"
+// @Param		version query string false "ICD-11 release, e.g. 2025-01; defaults to the configured release"
+// @Param		displayLanguage query string false "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation"
+// @Produce		json
+// @Success		200		{object}	dto.Parameters
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Router			/valueset/$validate-code [get]
+// @Router			/valueset/$validate-code [post]
+func (v *valueSetController) ValidateCode(ctx *gin.Context) {
+	params, err := operationParameters(ctx)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse parameters: %v", err))
+		return
+	}
+
+	url := params.Value("url")
+	system := params.Value("system")
+	code := params.Value("code")
+
+	if code == "" || (url == "" && system == "") {
+		respondInvalid(ctx, "code and either url or system are required")
+		return
+	}
+
+	parameters, err := v.valueSetService.ValidateCode(ctx.Request.Context(), url, system, code, params.Value("display"), versionOptions(params.Value))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, parameters)
+}
+
+// @Summary		Expand a value set
+// @Description	Expands the implicit value set of a code system, e.g. all NAMASTE codes ({namaste}?fhir_vs), all Siddha codes ({namaste}/siddha?fhir_vs) or ICD-11 ({icd}?fhir_vs), optionally filtered and paged. POST accepts the same inputs as a FHIR Parameters resource.
+// @Tags Value Set
+// @Param		url query string true "Value set URL"
+// @Param		filter query string false "Text filter"
+// @Param		offset query int false "Index of the first code to return. Without a filter, ICD-11 offset and count add up to at most 5000, and the total is only given once the last code is reached"
+// @Param		count query int false "Number of codes to return"
+// @Param		includeDesignations query bool false "Include native designations"
+// @Param		activeOnly query bool false "Only include active codes"
+// @Param		version query string false "ICD-11 release, e.g. 2025-01; defaults to the configured release"
+// @Param		displayLanguage query string false "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation"
+// @Produce		json
+// @Success		200		{object}	dto.ValueSet
+// @Failure		400		{object}	dto.OperationOutcome
+// @Failure		500		{object}	dto.OperationOutcome
+// @Failure		502		{object}	dto.OperationOutcome
+// @Router			/valueset/$expand [get]
+// @Router			/valueset/$expand [post]
+func (v *valueSetController) Expand(ctx *gin.Context) {
+	params, err := operationParameters(ctx)
+	if err != nil {
+		respondInvalid(ctx, fmt.Sprintf("unable to parse parameters: %v", err))
+		return
+	}
+
+	url := params.Value("url")
+	if url == "" {
+		respondInvalid(ctx, "url is required")
+		return
+	}
+
+	options := service.ExpandOptions{
+		VersionOptions: versionOptions(params.Value),
+		Filter:         params.Value("filter"),
+		Count:          defaultExpandCount,
+	}
+
+	for name, target := range map[string]*int{"offset": &options.Offset, "count": &options.Count} {
+		value := params.Value(name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			respondInvalid(ctx, fmt.Sprintf("invalid %s: %s", name, value))
+			return
+		}
+		*target = n
+	}
+	options.Count = min(options.Count, maxExpandCount)
+
+	for name, target := range map[string]*bool{"includeDesignations": &options.IncludeDesignations, "activeOnly": &options.ActiveOnly} {
+		value := params.Value(name)
+		if value == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			respondInvalid(ctx, fmt.Sprintf("invalid %s: %s", name, value))
+			return
+		}
+		*target = b
+	}
+
+	valueSet, err := v.valueSetService.Expand(ctx.Request.Context(), url, options)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, valueSet)
+}
+
+func NewValueSetController(valueSetService service.ValueSetService) ValueSetController {
+	return &valueSetController{
+		valueSetService: valueSetService,
+	}
+}
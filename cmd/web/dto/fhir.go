@@ -1,32 +1,50 @@
 package dto
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 type ValueSet struct {
 	ResourceType string    `json:"resourceType"` // ValueSet
 	ID           string    `json:"id"`           // autocomplete-results
-	Status       string    `json:"status"`       // active
+	URL          string    `json:"url,omitempty"`
+	Status       string    `json:"status"` // active
 	Expansion    Expansion `json:"expansion"`
 }
 
 type Expansion struct {
 	Identifier string    `json:"identifier"` // link to autocomplete endpoint
 	Timestamp  time.Time `json:"timestamp"`
-	Total      int       `json:"total"`  // 2
-	Offset     int       `json:"offset"` // 0
-	Contains   []Contain `json:"contains"`
+	// Total is the number of codes in the whole expansion, left out when it
+	// is not known.
+	Total    *int      `json:"total,omitempty"`
+	Offset   int       `json:"offset"` // 0
+	Contains []Contain `json:"contains"`
 }
 
 type Contain struct {
-	System    string    `json:"system"`  // link to namaste/icd code system page
-	Code      string    `json:"code"`    // code
-	Display   string    `json:"display"` // term
-	Extension Extension `json:"extension"`
+	System      string        `json:"system"`  // link to namaste/icd code system page
+	Code        string        `json:"code"`    // code
+	Display     string        `json:"display"` // term
+	Version     string        `json:"version,omitempty"`
+	Abstract    bool          `json:"abstract,omitempty"`
+	Designation []Designation `json:"designation,omitempty"`
+	// Extension holds the source system of the code and, for a suggested
+	// code, how it was paired with the searched one.
+	Extension []Extension `json:"extension"`
+}
+
+type Designation struct {
+	Language string `json:"language"` // sa/ta/ur
+	Value    string `json:"value"`    // native term
 }
 
 type Extension struct {
-	URL         string `json:"url"`         // link to structure defintion
-	ValueString string `json:"valueString"` // NAMASTE/ICD
+	URL          string   `json:"url"`                   // link to structure defintion
+	ValueString  string   `json:"valueString,omitempty"` // NAMASTE/ICD
+	ValueCode    string   `json:"valueCode,omitempty"`
+	ValueDecimal *float64 `json:"valueDecimal,omitempty"`
 }
 
 type CodeSystem struct {
@@ -34,6 +52,7 @@ type CodeSystem struct {
 	ID           string    `json:"id"`           // NAMASTE
 	URL          string    `json:"url"`
 	Version      string    `json:"version"`
+	Language     string    `json:"language,omitempty"`
 	Name         string    `json:"name"`    // NAMASTE Codes
 	Status       string    `json:"status"`  // active
 	Content      string    `json:"content"` // Complete
@@ -48,6 +67,176 @@ type Concept struct {
 }
 
 type Property struct {
-	Code        string `json:"code"`        // type
-	ValueString string `json:"valueString"` // ayurveda/siddha/unani
+	Code         string `json:"code"`                  // type
+	ValueString  string `json:"valueString,omitempty"` // ayurveda/siddha/unani
+	ValueCode    string `json:"valueCode,omitempty"`   // parent/child code
+	ValueBoolean *bool  `json:"valueBoolean,omitempty"`
+}
+
+type Parameters struct {
+	ResourceType string      `json:"resourceType"` // Parameters
+	Parameter    []Parameter `json:"parameter"`
+}
+
+type Parameter struct {
+	Name         string      `json:"name"`
+	ValueString  string      `json:"valueString,omitempty"`
+	ValueCode    string      `json:"valueCode,omitempty"`
+	ValueURI     string      `json:"valueUri,omitempty"`
+	ValueBoolean *bool       `json:"valueBoolean,omitempty"`
+	ValueInteger *int        `json:"valueInteger,omitempty"`
+	ValueCoding  *Coding     `json:"valueCoding,omitempty"`
+	Part         []Parameter `json:"part,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+// Value returns the primitive value of the named parameter, or an empty
+// string if the parameter is absent.
+func (p Parameters) Value(name string) string {
+	for _, param := range p.Parameter {
+		if param.Name != name {
+			continue
+		}
+
+		switch {
+		case param.ValueURI != "":
+			return param.ValueURI
+		case param.ValueCode != "":
+			return param.ValueCode
+		case param.ValueBoolean != nil:
+			return strconv.FormatBool(*param.ValueBoolean)
+		case param.ValueInteger != nil:
+			return strconv.Itoa(*param.ValueInteger)
+		default:
+			return param.ValueString
+		}
+	}
+
+	return ""
+}
+
+type ConceptMap struct {
+	ResourceType string  `json:"resourceType"` // ConceptMap
+	ID           string  `json:"id"`           // namaste-icd
+	URL          string  `json:"url"`
+	Version      string  `json:"version"`
+	Name         string  `json:"name"`
+	Status       string  `json:"status"` // active
+	SourceURI    string  `json:"sourceUri"`
+	TargetURI    string  `json:"targetUri"`
+	Group        []Group `json:"group"`
+}
+
+type Group struct {
+	Source  string    `json:"source"` // NAMASTE branch code system
+	Target  string    `json:"target"` // ICD code system
+	Element []Element `json:"element"`
+}
+
+type Element struct {
+	Code    string   `json:"code"`
+	Display string   `json:"display"`
+	Target  []Target `json:"target"`
+}
+
+type Target struct {
+	Code        string      `json:"code"`
+	Display     string      `json:"display"`
+	Equivalence string      `json:"equivalence"` // equivalent/wider/narrower/relatedto
+	Comment     string      `json:"comment,omitempty"`
+	Extension   []Extension `json:"extension,omitempty"`
+}
+
+type CapabilityStatement struct {
+	ResourceType   string         `json:"resourceType"` // CapabilityStatement
+	Status         string         `json:"status"`       // active
+	Date           string         `json:"date"`
+	Kind           string         `json:"kind"` // instance
+	Software       Software       `json:"software"`
+	Implementation Implementation `json:"implementation"`
+	FHIRVersion    string         `json:"fhirVersion"` // 4.0.1
+	Format         []string       `json:"format"`
+	Rest           []Rest         `json:"rest"`
+}
+
+type Software struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Implementation struct {
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
+
+type Rest struct {
+	Mode     string         `json:"mode"` // server
+	Resource []RestResource `json:"resource"`
+}
+
+type RestResource struct {
+	Type        string        `json:"type"` // CodeSystem/ValueSet/ConceptMap
+	Interaction []Interaction `json:"interaction,omitempty"`
+	Operation   []Operation   `json:"operation,omitempty"`
+}
+
+type Interaction struct {
+	Code string `json:"code"` // read
+}
+
+type Operation struct {
+	Name       string `json:"name"` // lookup
+	Definition string `json:"definition"`
+}
+
+type TerminologyCapabilities struct {
+	ResourceType string                  `json:"resourceType"` // TerminologyCapabilities
+	Status       string                  `json:"status"`       // active
+	Date         string                  `json:"date"`
+	Kind         string                  `json:"kind"` // instance
+	Software     Software                `json:"software"`
+	CodeSystem   []TerminologyCodeSystem `json:"codeSystem"`
+	Expansion    *TerminologyExpansion   `json:"expansion,omitempty"`
+	ValidateCode *TerminologyValidate    `json:"validateCode,omitempty"`
+	Translation  *TerminologyTranslation `json:"translation,omitempty"`
+}
+
+type TerminologyCodeSystem struct {
+	URI     string              `json:"uri"`
+	Version []CodeSystemVersion `json:"version"`
+}
+
+type CodeSystemVersion struct {
+	Code      string `json:"code"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+type TerminologyExpansion struct {
+	Hierarchical bool   `json:"hierarchical"`
+	Paging       bool   `json:"paging"`
+	TextFilter   string `json:"textFilter"`
+}
+
+type TerminologyValidate struct {
+	Translations bool `json:"translations"`
+}
+
+type TerminologyTranslation struct {
+	NeedsMap bool `json:"needsMap"`
+}
+
+type OperationOutcome struct {
+	ResourceType string  `json:"resourceType"` // OperationOutcome
+	Issue        []Issue `json:"issue"`
+}
+
+type Issue struct {
+	Severity    string `json:"severity"` // error
+	Code        string `json:"code"`     // invalid/not-found/exception/transient
+	Diagnostics string `json:"diagnostics"`
 }
@@ -21,3 +21,27 @@ type DestinationEntity struct {
 type SearchResponse struct {
 	DestinationEntities []DestinationEntity `json:"destinationEntities"`
 }
+
+type CodeInfoResponse struct {
+	Code   string `json:"code"`
+	StemID string `json:"stemId"`
+}
+
+type LanguageValue struct {
+	Language string `json:"@language"`
+	Value    string `json:"@value"`
+}
+
+type EntityResponse struct {
+	ID         string        `json:"@id"`
+	Code       string        `json:"code"`
+	BlockID    string        `json:"blockId"`
+	ClassKind  string        `json:"classKind"`
+	Title      LanguageValue `json:"title"`
+	Definition LanguageValue `json:"definition"`
+	Parent     []string      `json:"parent"`
+	Child      []string      `json:"child"`
+
+	// AvailableLanguages is only given for the root of a linearization.
+	AvailableLanguages []string `json:"availableLanguages"`
+}
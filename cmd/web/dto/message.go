@@ -3,7 +3,3 @@ package dto
 type Message struct {
 	Message string `json:"message"`
 }
-
-type Error struct {
-	Error string `json:"error"`
-}
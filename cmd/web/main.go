@@ -6,9 +6,11 @@ import (
 	"backend/internal/repository"
 	"backend/internal/service"
 	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cache"
@@ -24,6 +26,10 @@ import (
 	"google.golang.org/genai"
 )
 
+// @securityDefinitions.apikey	ReviewerToken
+// @in							header
+// @name						Authorization
+// @description				"Bearer " followed by the token of a reviewer in REVIEWER_TOKENS
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -55,28 +61,207 @@ func main() {
 
 	httpClient := http.Client{}
 
-	// Get the ICD API IDs etc
-	icdClientID := os.Getenv("ICD_CLIENTID")
-	icdClientSecret := os.Getenv("ICD_CLIENTSECRET")
+	// Get the ICD API IDs etc. ICD_API_URL and ICD_TOKEN_URL point to another
+	// server than WHO's, such as a stand-in for testing
+	whoEndpoint := repository.WHOEndpoint{
+		APIURL:       os.Getenv("ICD_API_URL"),
+		TokenURL:     os.Getenv("ICD_TOKEN_URL"),
+		ClientID:     os.Getenv("ICD_CLIENTID"),
+		ClientSecret: os.Getenv("ICD_CLIENTSECRET"),
+	}
+
+	// How calls to the WHO API are retried: WHO_TIMEOUT bounds each attempt,
+	// such as "10s", and WHO_RETRIES is the number of retries after a 429,
+	// 5xx or network error
+	whoOptions := repository.DefaultWHOClientOptions
+	if value := os.Getenv("WHO_TIMEOUT"); value != "" {
+		whoOptions.Timeout, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalln("Invalid WHO_TIMEOUT: " + err.Error())
+		}
+	}
+	if value := os.Getenv("WHO_RETRIES"); value != "" {
+		whoOptions.Retries, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalln("Invalid WHO_RETRIES: " + err.Error())
+		}
+	}
+
+	// Which ICD-11 release, such as "2025-01", linearization, such as "mms",
+	// and language, such as "hi", are served unless a request asks for
+	// another. ICD_SOURCE local serves the edition its index was imported from
+	icdEdition := repository.ICDEdition{
+		Release:       os.Getenv("ICD_RELEASE"),
+		Linearization: os.Getenv("ICD_LINEARIZATION"),
+		Language:      os.Getenv("ICD_LANGUAGE"),
+	}
+
+	// Where the NAMASTE to ICD mappings are persisted
+	conceptMapPath := os.Getenv("CONCEPTMAP_DB")
+	if conceptMapPath == "" {
+		conceptMapPath = "conceptmap.db"
+	}
+
+	// Where the NAMASTE index generations are built, as <path>.<generation>
+	namasteIndexPath := os.Getenv("NAMASTE_INDEX")
+	if namasteIndexPath == "" {
+		namasteIndexPath = "index.bleve"
+	}
+
+	// Where the NAMASTE CSV files are read from
+	namasteAssets := os.Getenv("NAMASTE_ASSETS")
+	if namasteAssets == "" {
+		namasteAssets = "assets"
+	}
+
+	// How NAMASTE and ICD-11 matches are paired: "llm" asks the language model
+	// and falls back to lexical matching if it fails, "lexical" never calls
+	// out to a language model
+	var matcher service.Matcher
+	switch os.Getenv("MATCHER") {
+	case "", "llm":
+		// Which language model serves the "llm" matcher: "gemini" uses
+		// Google's API, "openai" any server with an OpenAI compatible chat
+		// completions API at LLM_BASE_URL, such as an on-premises model
+		llmModel := os.Getenv("LLM_MODEL")
+
+		var provider service.LLMProvider
+		switch os.Getenv("LLM_PROVIDER") {
+		case "", "gemini":
+			if llmModel == "" {
+				llmModel = service.DefaultGeminiModel
+			}
+
+			genaiClient, err := genai.NewClient(context.Background(), nil)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			provider = service.NewGeminiProvider(genaiClient, llmModel)
+		case "openai":
+			llmBaseURL := os.Getenv("LLM_BASE_URL")
+			if llmBaseURL == "" {
+				llmBaseURL = "https://api.openai.com/v1"
+			}
+			if llmModel == "" {
+				log.Fatalln("LLM_MODEL is required with LLM_PROVIDER openai")
+			}
+
+			provider = service.NewOpenAIProvider(&httpClient, llmBaseURL, os.Getenv("LLM_API_KEY"), llmModel)
+		default:
+			log.Fatalln("Unknown LLM_PROVIDER " + os.Getenv("LLM_PROVIDER"))
+		}
+		matcher = service.NewModelMatcher(provider)
+	case "lexical":
+		matcher = service.NewLexicalMatcher()
+	default:
+		log.Fatalln("Unknown MATCHER " + os.Getenv("MATCHER"))
+	}
+
+	// How long autocomplete waits for each source, such as "10s", before
+	// answering without it
+	timeouts := service.DefaultSourceTimeouts
+	if value := os.Getenv("ICD_TIMEOUT"); value != "" {
+		timeouts.ICD, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalln("Invalid ICD_TIMEOUT: " + err.Error())
+		}
+	}
+	if value := os.Getenv("NAMASTE_TIMEOUT"); value != "" {
+		timeouts.Namaste, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalln("Invalid NAMASTE_TIMEOUT: " + err.Error())
+		}
+	}
+
+	// Set up the repositories
+	var icdRepository repository.ICDRepository
+	if os.Getenv("ICD_SOURCE") == "local" {
+		// Serve ICD-11 from an index built by cmd/icdimport instead of the WHO API
+		icdIndexPath := os.Getenv("ICD_INDEX")
+		if icdIndexPath == "" {
+			icdIndexPath = "icd.bleve"
+		}
+
+		icdRepository, err = repository.NewLocalICDRepository(icdIndexPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		// Where the WHO API responses are kept across restarts, or "off"
+		icdCachePath := os.Getenv("ICD_CACHE")
+		if icdCachePath == "" {
+			icdCachePath = "icd-cache.db"
+		}
 
-	genaiClient, err := genai.NewClient(context.Background(), nil)
+		var icdCache repository.ICDCache
+		if icdCachePath != "off" {
+			// ICD_CACHE_TTL is how long a response is kept, such as "720h", and
+			// ICD_CACHE_MAX_MB bounds the size of the cache
+			cacheOptions := repository.DefaultICDCacheOptions
+			if value := os.Getenv("ICD_CACHE_TTL"); value != "" {
+				cacheOptions.TTL, err = time.ParseDuration(value)
+				if err != nil {
+					log.Fatalln("Invalid ICD_CACHE_TTL: " + err.Error())
+				}
+			}
+			if value := os.Getenv("ICD_CACHE_MAX_MB"); value != "" {
+				maxMB, err := strconv.Atoi(value)
+				if err != nil {
+					log.Fatalln("Invalid ICD_CACHE_MAX_MB: " + err.Error())
+				}
+				cacheOptions.MaxBytes = int64(maxMB) << 20
+			}
+
+			icdCache, err = repository.NewICDCache(icdCachePath, cacheOptions)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		icdRepository, err = repository.NewICDRepository(&httpClient, whoEndpoint, whoOptions, icdCache, icdEdition)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	namasteRepository, err := repository.NewNamasteRepository(namasteIndexPath, namasteAssets)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	conceptMapRepository, err := repository.NewConceptMapRepository(conceptMapPath)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	// Set up the repositories
-	icdRepository := repository.NewICDRepository(&httpClient, icdClientID, icdClientSecret)
-	namasteRepository := repository.NewNamasteRepository()
+	// Who may accept, reject and edit mappings, as comma separated
+	// name:token pairs. The name is recorded as the reviewer of the mappings
+	// reviewed with the token. Nobody may without it
+	reviewers, err := controller.ParseReviewers(os.Getenv("REVIEWER_TOKENS"))
+	if err != nil {
+		log.Fatalln("Invalid REVIEWER_TOKENS: " + err.Error())
+	}
+	if len(reviewers) == 0 {
+		log.Println("REVIEWER_TOKENS NOT SET, mappings cannot be reviewed")
+	}
 
 	// Set up services
-	autocompleteService := service.NewAutoComplete(genaiClient, icdRepository, namasteRepository)
+	autocompleteService := service.NewAutoComplete(matcher, timeouts, icdRepository, namasteRepository, conceptMapRepository)
 	codeSystemService := service.NewCodeSystemService(namasteRepository, icdRepository)
+	typeaheadService := service.NewTypeaheadService(namasteRepository)
+	valueSetService := service.NewValueSetService(codeSystemService, namasteRepository, icdRepository)
+	conceptMapService := service.NewConceptMapService(conceptMapRepository)
+	mappingService := service.NewMappingService(conceptMapRepository, icdRepository)
+	metadataService := service.NewMetadataService(docs.SwaggerInfo.Title, docs.SwaggerInfo.Version, docs.SwaggerInfo.Description, icdRepository.Edition().Release)
 
 	// Set up controllers
 	autocompleteController := controller.NewAutocompleteController(autocompleteService)
 	databaseController := controller.NewDatabaseController(autocompleteService)
 	serverController := controller.NewServerController()
 	codeSystemController := controller.NewCodeSystemController(codeSystemService)
+	valueSetController := controller.NewValueSetController(valueSetService)
+	typeaheadController := controller.NewTypeaheadController(typeaheadService)
+	conceptMapController := controller.NewConceptMapController(conceptMapService)
+	mappingController := controller.NewMappingController(mappingService)
+	metadataController := controller.NewMetadataController(metadataService, r.Routes, docs.SwaggerInfo.BasePath)
 
 	// Rate limiter
 	rate, err := limiter.NewRateFromFormatted("20-M")
@@ -86,10 +271,20 @@ func main() {
 
 	// Setup middlewares
 	rateLimitStore := memory.NewStore()
-	rateLimiterMiddleware := mgin.NewMiddleware(limiter.New(rateLimitStore, rate))
+	rateLimiterMiddleware := mgin.NewMiddleware(limiter.New(rateLimitStore, rate), mgin.WithLimitReachedHandler(controller.LimitReached))
+
+	// Typeahead is called on every keystroke and only touches the local
+	// index, so it gets a limit of its own
+	typeaheadRate, err := limiter.NewRateFromFormatted("20-S")
+	if err != nil {
+		log.Fatalln("Failed to create rate limiter: %w", err)
+	}
+	typeaheadLimiterMiddleware := mgin.NewMiddleware(limiter.New(memory.NewStore(), typeaheadRate), mgin.WithLimitReachedHandler(controller.LimitReached))
 
 	cacheStore := persistence.NewInMemoryStore(time.Hour)
 
+	r.GET(docs.SwaggerInfo.BasePath+"/typeahead", typeaheadLimiterMiddleware, typeaheadController.Suggest)
+
 	apiRoutes := r.Group(docs.SwaggerInfo.BasePath)
 	apiRoutes.Use(rateLimiterMiddleware)
 	{
@@ -103,17 +298,67 @@ func main() {
 				c.Header("Content-Type", "application/json; charset=utf-8")
 				cache.CachePageWithoutHeader(cacheStore, time.Hour, codeSystemController.ListICD)(c)
 			})
+			codeSystemRoutes.GET("/icd-tm2", func(c *gin.Context) {
+				c.Header("Content-Type", "application/json; charset=utf-8")
+				cache.CachePageWithoutHeader(cacheStore, time.Hour, codeSystemController.ListTM2)(c)
+			})
+			codeSystemRoutes.GET("/$lookup", codeSystemController.Lookup)
+			codeSystemRoutes.POST("/$lookup", codeSystemController.Lookup)
+			codeSystemRoutes.GET("/$validate-code", codeSystemController.ValidateCode)
+			codeSystemRoutes.POST("/$validate-code", codeSystemController.ValidateCode)
+		}
+
+		valueSetRoutes := apiRoutes.Group("/valueset")
+		{
+			valueSetRoutes.GET("/$validate-code", valueSetController.ValidateCode)
+			valueSetRoutes.POST("/$validate-code", valueSetController.ValidateCode)
+			valueSetRoutes.GET("/$expand", valueSetController.Expand)
+			valueSetRoutes.POST("/$expand", valueSetController.Expand)
+		}
+
+		conceptMapRoutes := apiRoutes.Group("/conceptmap")
+		{
+			conceptMapRoutes.GET("", conceptMapController.Get)
+			conceptMapRoutes.GET("/$translate", conceptMapController.Translate)
+			conceptMapRoutes.POST("/$translate", conceptMapController.Translate)
+		}
+
+		mappingRoutes := apiRoutes.Group("/mapping")
+		{
+			mappingRoutes.GET("", mappingController.List)
+
+			// Only reviewers may curate the mappings
+			reviewRoutes := mappingRoutes.Group("", controller.RequireReviewer(reviewers))
+			reviewRoutes.PUT("/:id", mappingController.Edit)
+			reviewRoutes.POST("/:id/accept", mappingController.Accept)
+			reviewRoutes.POST("/:id/reject", mappingController.Reject)
 		}
 
 		apiRoutes.GET("/sync", databaseController.Sync)
-		apiRoutes.GET("/autocomplete", func(c *gin.Context) {
-			c.Header("Content-Type", "application/json; charset=utf-8")
-			cache.CachePageWithoutHeader(cacheStore, time.Hour, autocompleteController.Find)(c)
-		})
+		// Autocomplete is not cached, as it changes with every review of a
+		// mapping
+		apiRoutes.GET("/autocomplete", autocompleteController.Find)
 		apiRoutes.GET("/health", serverController.Health)
+		apiRoutes.GET("/metadata", metadataController.Metadata)
 	}
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.NoRoute(controller.NotFound)
+
+	// Counters of the WHO API calls, among other runtime variables, are
+	// served at /debug/vars on a listener of their own, which is only
+	// reachable from the host unless DEBUG_ADDR says otherwise
+	debugAddr := os.Getenv("DEBUG_ADDR")
+	if debugAddr == "" {
+		debugAddr = "127.0.0.1:6060"
+	}
+	go func() {
+		debugMux := http.NewServeMux()
+		debugMux.Handle("/debug/vars", expvar.Handler())
+		if err := http.ListenAndServe(debugAddr, debugMux); err != nil {
+			log.Println("Error: unable to serve /debug/vars on " + debugAddr + ": " + err.Error())
+		}
+	}()
 
 	r.Run(":" + port)
 }
@@ -25,10 +25,28 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Search query",
+                        "description": "Search query, or an ICD-11 cluster such as SA80/SF57 to find the NAMASTE diseases mapped to it",
                         "name": "query",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 module to match: tm2 or biomedicine. Both are matched for dual coding when empty",
+                        "name": "module",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the query, such as sa, ta or ur for the native NAMASTE terms. Guessed from the script of the query when empty",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Leave out the pairs with a lower confidence, from 0 to 1. Curated pairs have a confidence of 1",
+                        "name": "minConfidence",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -39,31 +57,76 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/dto.ValueSet"
                             }
+                        },
+                        "headers": {
+                            "Warning": {
+                                "type": "string",
+                                "description": "Set when a source timed out and the matches may be incomplete"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/dto.Error"
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     }
                 }
             }
         },
-        "/codesystem/icd": {
+        "/codesystem/$lookup": {
             "get": {
+                "description": "Returns the display, definition, native designations and properties of a single NAMASTE or ICD code. POST accepts the same inputs as a FHIR Parameters resource.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Code System"
                 ],
-                "summary": "List all ICD codes",
+                "summary": "Look up a code",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Number of codes you want",
-                        "name": "size",
+                        "type": "string",
+                        "description": "Code system URL",
+                        "name": "system",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to look up",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
                         "in": "query"
                     }
                 ],
@@ -71,32 +134,147 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.CodeSystem"
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Returns the display, definition, native designations and properties of a single NAMASTE or ICD code. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Code System"
+                ],
+                "summary": "Look up a code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code system URL",
+                        "name": "system",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to look up",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/dto.Error"
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     }
                 }
             }
         },
-        "/codesystem/namaste": {
+        "/codesystem/$validate-code": {
             "get": {
+                "description": "Checks that a code, and optionally its display, belongs to a NAMASTE or ICD code system. POST accepts the same inputs as a FHIR Parameters resource.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Code System"
                 ],
-                "summary": "List all namaste codes",
+                "summary": "Validate a code",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Number of codes you want",
-                        "name": "size",
+                        "type": "string",
+                        "description": "Code system URL",
+                        "name": "system",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to validate. ICD-11 codes may be postcoordinated clusters, e.g. 1A00\u0026XS25 or SA80/SF57",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Display to check against the code",
+                        "name": "display",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
                         "in": "query"
                     }
                 ],
@@ -104,88 +282,1236 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.CodeSystem"
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Checks that a code, and optionally its display, belongs to a NAMASTE or ICD code system. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Code System"
+                ],
+                "summary": "Validate a code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code system URL",
+                        "name": "system",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to validate. ICD-11 codes may be postcoordinated clusters, e.g. 1A00\u0026XS25 or SA80/SF57",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Display to check against the code",
+                        "name": "display",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/dto.Error"
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     }
                 }
             }
         },
-        "/health": {
+        "/codesystem/icd": {
             "get": {
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Check if server is alive",
+                "tags": [
+                    "Code System"
+                ],
+                "summary": "List all ICD codes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of codes you want",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.Message"
+                            "$ref": "#/definitions/dto.CodeSystem"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     }
                 }
             }
         },
-        "/sync": {
+        "/codesystem/icd-tm2": {
             "get": {
-                "description": "Updates the database index with the NAMASTE CSV files' data",
+                "description": "Lists the ICD-11 Traditional Medicine Module 2 codes, the chapter NAMASTE codes map to most naturally",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Syncs databases",
+                "tags": [
+                    "Code System"
+                ],
+                "summary": "List all ICD-11 TM2 codes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of codes you want",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.Message"
+                            "$ref": "#/definitions/dto.CodeSystem"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/dto.Error"
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.CodeSystem": {
-            "type": "object",
-            "properties": {
-                "concept": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/dto.Concept"
-                    }
-                },
-                "content": {
-                    "description": "Complete",
+        },
+        "/codesystem/namaste": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Code System"
+                ],
+                "summary": "List all namaste codes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of codes you want",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CodeSystem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/conceptmap": {
+            "get": {
+                "description": "Returns every stored NAMASTE to ICD-11 mapping as a FHIR ConceptMap",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Concept Map"
+                ],
+                "summary": "Get the NAMASTE to ICD-11 concept map",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ConceptMap"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/conceptmap/$translate": {
+            "get": {
+                "description": "Translates a NAMASTE code to ICD-11, or an ICD-11 code to NAMASTE, using the stored concept map. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Concept Map"
+                ],
+                "summary": "Translate a code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code system URL of the code",
+                        "name": "system",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to translate",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code system URL to translate into",
+                        "name": "target",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Translates a NAMASTE code to ICD-11, or an ICD-11 code to NAMASTE, using the stored concept map. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Concept Map"
+                ],
+                "summary": "Translate a code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code system URL of the code",
+                        "name": "system",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to translate",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code system URL to translate into",
+                        "name": "target",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Check if server is alive",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Message"
+                        }
+                    }
+                }
+            }
+        },
+        "/mapping": {
+            "get": {
+                "description": "Lists the stored NAMASTE to ICD-11 mappings, optionally only those with the given review status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Mapping"
+                ],
+                "summary": "List mappings",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "proposed, accepted or rejected",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.Mapping"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/mapping/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ReviewerToken": []
+                    }
+                ],
+                "description": "Replaces the ICD-11 side of a mapping, which accepts it. The code must exist in ICD-11, and its display is looked up. A pair that another mapping already holds is a conflict.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Mapping"
+                ],
+                "summary": "Edit a mapping",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Mapping id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New ICD code or cluster, equivalence and comment",
+                        "name": "edit",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.MappingEdit"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repository.Mapping"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/mapping/{id}/accept": {
+            "post": {
+                "security": [
+                    {
+                        "ReviewerToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Mapping"
+                ],
+                "summary": "Accept a mapping",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Mapping id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Comment on the review",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.MappingReview"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repository.Mapping"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/mapping/{id}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "ReviewerToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Mapping"
+                ],
+                "summary": "Reject a mapping",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Mapping id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Comment on the review",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.MappingReview"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/repository.Mapping"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/metadata": {
+            "get": {
+                "description": "Returns the FHIR CapabilityStatement of the server, or its TerminologyCapabilities with mode=terminology",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Get the server capabilities",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "full or terminology",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CapabilityStatement"
+                        }
+                    }
+                }
+            }
+        },
+        "/sync": {
+            "get": {
+                "description": "Rebuilds the NAMASTE index from the CSV files. Searches keep using the previous index until the new one is complete",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Syncs databases",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Message"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/typeahead": {
+            "get": {
+                "description": "Suggests NAMASTE codes while a term is being typed, tolerating unfinished words and typos. Exact words rank above prefixes, which rank above near misses. Unlike autocomplete, no ICD-11 pairing is done, so it is fast enough to call on every keystroke.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Complete a partial NAMASTE term",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Partial term",
+                        "name": "query",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "NAMASTE code system or branch system URL",
+                        "name": "system",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the query, such as sa, ta or ur. Guessed from the script of the query when empty",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of suggestions",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ValueSet"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/valueset/$expand": {
+            "get": {
+                "description": "Expands the implicit value set of a code system, e.g. all NAMASTE codes ({namaste}?fhir_vs), all Siddha codes ({namaste}/siddha?fhir_vs) or ICD-11 ({icd}?fhir_vs), optionally filtered and paged. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Value Set"
+                ],
+                "summary": "Expand a value set",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Value set URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text filter",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Index of the first code to return. Without a filter, ICD-11 offset and count add up to at most 5000, and the total is only given once the last code is reached",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of codes to return",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include native designations",
+                        "name": "includeDesignations",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only include active codes",
+                        "name": "activeOnly",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ValueSet"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Expands the implicit value set of a code system, e.g. all NAMASTE codes ({namaste}?fhir_vs), all Siddha codes ({namaste}/siddha?fhir_vs) or ICD-11 ({icd}?fhir_vs), optionally filtered and paged. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Value Set"
+                ],
+                "summary": "Expand a value set",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Value set URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text filter",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Index of the first code to return. Without a filter, ICD-11 offset and count add up to at most 5000, and the total is only given once the last code is reached",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of codes to return",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include native designations",
+                        "name": "includeDesignations",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only include active codes",
+                        "name": "activeOnly",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ValueSet"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        },
+        "/valueset/$validate-code": {
+            "get": {
+                "description": "Checks that a code, and optionally its display, is in a value set. The implicit value sets of the code systems are addressed as the code system URL followed by ?fhir_vs. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Value Set"
+                ],
+                "summary": "Validate a code against a value set",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Value set URL",
+                        "name": "url",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code system URL, required without url",
+                        "name": "system",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to validate",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Display to check against the code",
+                        "name": "display",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Checks that a code, and optionally its display, is in a value set. The implicit value sets of the code systems are addressed as the code system URL followed by ?fhir_vs. POST accepts the same inputs as a FHIR Parameters resource.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Value Set"
+                ],
+                "summary": "Validate a code against a value set",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Value set URL",
+                        "name": "url",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code system URL, required without url",
+                        "name": "system",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Code to validate",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Display to check against the code",
+                        "name": "display",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ICD-11 release, e.g. 2025-01; defaults to the configured release",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the ICD-11 displays, e.g. hi; English where WHO has no translation",
+                        "name": "displayLanguage",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Parameters"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/dto.OperationOutcome"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.CapabilityStatement": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string"
+                },
+                "fhirVersion": {
+                    "description": "4.0.1",
+                    "type": "string"
+                },
+                "format": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "implementation": {
+                    "$ref": "#/definitions/dto.Implementation"
+                },
+                "kind": {
+                    "description": "instance",
+                    "type": "string"
+                },
+                "resourceType": {
+                    "description": "CapabilityStatement",
+                    "type": "string"
+                },
+                "rest": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Rest"
+                    }
+                },
+                "software": {
+                    "$ref": "#/definitions/dto.Software"
+                },
+                "status": {
+                    "description": "active",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CodeSystem": {
+            "type": "object",
+            "properties": {
+                "concept": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Concept"
+                    }
+                },
+                "content": {
+                    "description": "Complete",
                     "type": "string"
                 },
                 "id": {
                     "description": "NAMASTE",
                     "type": "string"
                 },
+                "language": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "NAMASTE Codes",
+                    "type": "string"
+                },
+                "resourceType": {
+                    "description": "CodeSystem",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "active",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Coding": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "display": {
+                    "type": "string"
+                },
+                "system": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Concept": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "code",
+                    "type": "string"
+                },
+                "definition": {
+                    "description": "longDesc",
+                    "type": "string"
+                },
+                "display": {
+                    "description": "Term",
+                    "type": "string"
+                },
+                "property": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Property"
+                    }
+                }
+            }
+        },
+        "dto.ConceptMap": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Group"
+                    }
+                },
+                "id": {
+                    "description": "namaste-icd",
+                    "type": "string"
+                },
                 "name": {
-                    "description": "NAMASTE Codes",
                     "type": "string"
                 },
                 "resourceType": {
-                    "description": "CodeSystem",
+                    "description": "ConceptMap",
+                    "type": "string"
+                },
+                "sourceUri": {
                     "type": "string"
                 },
                 "status": {
                     "description": "active",
                     "type": "string"
                 },
+                "targetUri": {
+                    "type": "string"
+                },
                 "url": {
                     "type": "string"
                 },
@@ -194,54 +1520,69 @@ const docTemplate = `{
                 }
             }
         },
-        "dto.Concept": {
+        "dto.Contain": {
             "type": "object",
             "properties": {
+                "abstract": {
+                    "type": "boolean"
+                },
                 "code": {
                     "description": "code",
                     "type": "string"
                 },
-                "definition": {
-                    "description": "longDesc",
-                    "type": "string"
+                "designation": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Designation"
+                    }
                 },
                 "display": {
-                    "description": "Term",
+                    "description": "term",
                     "type": "string"
                 },
-                "property": {
+                "extension": {
+                    "description": "Extension holds the source system of the code and, for a suggested\ncode, how it was paired with the searched one.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dto.Property"
+                        "$ref": "#/definitions/dto.Extension"
                     }
+                },
+                "system": {
+                    "description": "link to namaste/icd code system page",
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
                 }
             }
         },
-        "dto.Contain": {
+        "dto.Designation": {
             "type": "object",
             "properties": {
-                "code": {
-                    "description": "code",
-                    "type": "string"
-                },
-                "display": {
-                    "description": "term",
+                "language": {
+                    "description": "sa/ta/ur",
                     "type": "string"
                 },
-                "extension": {
-                    "$ref": "#/definitions/dto.Extension"
-                },
-                "system": {
-                    "description": "link to namaste/icd code system page",
+                "value": {
+                    "description": "native term",
                     "type": "string"
                 }
             }
         },
-        "dto.Error": {
+        "dto.Element": {
             "type": "object",
             "properties": {
-                "error": {
+                "code": {
+                    "type": "string"
+                },
+                "display": {
                     "type": "string"
+                },
+                "target": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Target"
+                    }
                 }
             }
         },
@@ -266,7 +1607,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "total": {
-                    "description": "2",
+                    "description": "Total is the number of codes in the whole expansion, left out when it\nis not known.",
                     "type": "integer"
                 }
             }
@@ -278,12 +1619,73 @@ const docTemplate = `{
                     "description": "link to structure defintion",
                     "type": "string"
                 },
+                "valueCode": {
+                    "type": "string"
+                },
+                "valueDecimal": {
+                    "type": "number"
+                },
                 "valueString": {
                     "description": "NAMASTE/ICD",
                     "type": "string"
                 }
             }
         },
+        "dto.Group": {
+            "type": "object",
+            "properties": {
+                "element": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Element"
+                    }
+                },
+                "source": {
+                    "description": "NAMASTE branch code system",
+                    "type": "string"
+                },
+                "target": {
+                    "description": "ICD code system",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Implementation": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Interaction": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "read",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Issue": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "invalid/not-found/exception/transient",
+                    "type": "string"
+                },
+                "diagnostics": {
+                    "type": "string"
+                },
+                "severity": {
+                    "description": "error",
+                    "type": "string"
+                }
+            }
+        },
         "dto.Message": {
             "type": "object",
             "properties": {
@@ -292,6 +1694,80 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.Operation": {
+            "type": "object",
+            "properties": {
+                "definition": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "lookup",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.OperationOutcome": {
+            "type": "object",
+            "properties": {
+                "issue": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Issue"
+                    }
+                },
+                "resourceType": {
+                    "description": "OperationOutcome",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Parameter": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "part": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Parameter"
+                    }
+                },
+                "valueBoolean": {
+                    "type": "boolean"
+                },
+                "valueCode": {
+                    "type": "string"
+                },
+                "valueCoding": {
+                    "$ref": "#/definitions/dto.Coding"
+                },
+                "valueInteger": {
+                    "type": "integer"
+                },
+                "valueString": {
+                    "type": "string"
+                },
+                "valueUri": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Parameters": {
+            "type": "object",
+            "properties": {
+                "parameter": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Parameter"
+                    }
+                },
+                "resourceType": {
+                    "description": "Parameters",
+                    "type": "string"
+                }
+            }
+        },
         "dto.Property": {
             "type": "object",
             "properties": {
@@ -299,12 +1775,90 @@ const docTemplate = `{
                     "description": "type",
                     "type": "string"
                 },
+                "valueBoolean": {
+                    "type": "boolean"
+                },
+                "valueCode": {
+                    "description": "parent/child code",
+                    "type": "string"
+                },
                 "valueString": {
                     "description": "ayurveda/siddha/unani",
                     "type": "string"
                 }
             }
         },
+        "dto.Rest": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "description": "server",
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.RestResource"
+                    }
+                }
+            }
+        },
+        "dto.RestResource": {
+            "type": "object",
+            "properties": {
+                "interaction": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Interaction"
+                    }
+                },
+                "operation": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Operation"
+                    }
+                },
+                "type": {
+                    "description": "CodeSystem/ValueSet/ConceptMap",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Software": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Target": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "display": {
+                    "type": "string"
+                },
+                "equivalence": {
+                    "description": "equivalent/wider/narrower/relatedto",
+                    "type": "string"
+                },
+                "extension": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Extension"
+                    }
+                }
+            }
+        },
         "dto.ValueSet": {
             "type": "object",
             "properties": {
@@ -322,8 +1876,94 @@ const docTemplate = `{
                 "status": {
                     "description": "active",
                     "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "repository.Mapping": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "confidence": {
+                    "type": "number"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "equivalence": {
+                    "type": "string"
+                },
+                "icdCode": {
+                    "type": "string"
+                },
+                "icdName": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "namasteCode": {
+                    "type": "string"
+                },
+                "namasteName": {
+                    "type": "string"
+                },
+                "namasteType": {
+                    "type": "string"
+                },
+                "rationale": {
+                    "type": "string"
+                },
+                "reviewedAt": {
+                    "type": "string"
+                },
+                "reviewer": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.MappingEdit": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "equivalence": {
+                    "type": "string"
+                },
+                "icdCode": {
+                    "type": "string"
                 }
             }
+        },
+        "service.MappingReview": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ReviewerToken": {
+            "description": "\"Bearer \" followed by the token of a reviewer in REVIEWER_TOKENS",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	mappingsBucket = []byte("mappings")
+	// pairsBucket holds the id of the mapping of each NAMASTE/ICD pair, by
+	// pairKey.
+	pairsBucket = []byte("pairs")
+	// namasteIndexBucket and icdIndexBucket hold an empty value under the
+	// indexKey of the NAMASTE and the ICD code of each mapping.
+	namasteIndexBucket = []byte("namaste_index")
+	icdIndexBucket     = []byte("icd_index")
+)
+
+// Review states of a mapping. Suggested mappings start out proposed until a
+// terminologist accepts or rejects them.
+const (
+	StatusProposed = "proposed"
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+)
+
+// Mapping is a persisted pairing of a NAMASTE concept with an ICD-11 concept.
+// Equivalence describes the ICD concept relative to the NAMASTE concept using
+// the FHIR ConceptMap equivalence codes. Confidence and Rationale are given
+// by the matcher that proposed the mapping.
+type Mapping struct {
+	ID          uint64    `json:"id"`
+	NamasteType string    `json:"namasteType"`
+	NamasteCode string    `json:"namasteCode"`
+	NamasteName string    `json:"namasteName"`
+	ICDCode     string    `json:"icdCode"`
+	ICDName     string    `json:"icdName"`
+	Equivalence string    `json:"equivalence"`
+	Source      string    `json:"source"`
+	Confidence  float64   `json:"confidence"`
+	Rationale   string    `json:"rationale,omitempty"`
+	Status      string    `json:"status"`
+	Comment     string    `json:"comment"`
+	Reviewer    string    `json:"reviewer"`
+	ReviewedAt  time.Time `json:"reviewedAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type ConceptMapRepository interface {
+	Propose(mappings []Mapping) error
+	Get(id uint64) (*Mapping, error)
+	Update(mapping Mapping) error
+	List() ([]Mapping, error)
+	FindByNamaste(code string) ([]Mapping, error)
+	FindByICD(code string) ([]Mapping, error)
+}
+
+type conceptMapRepository struct {
+	db *bolt.DB
+}
+
+func NewConceptMapRepository(path string) (ConceptMapRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open concept map store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{mappingsBucket, pairsBucket, namasteIndexBucket, icdIndexBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		// Stores written before the indexes existed are indexed once.
+		if k, _ := tx.Bucket(pairsBucket).Cursor().First(); k != nil {
+			return nil
+		}
+		return tx.Bucket(mappingsBucket).ForEach(func(_, v []byte) error {
+			var mapping Mapping
+			if err := json.Unmarshal(v, &mapping); err != nil {
+				return err
+			}
+			return indexMapping(tx, mapping)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create concept map buckets: %w", err)
+	}
+
+	return &conceptMapRepository{db: db}, nil
+}
+
+// Propose implements ConceptMapRepository. Suggestions are stored as
+// proposed. A suggestion for a NAMASTE/ICD pair that is already stored only
+// refreshes a still proposed mapping and never touches a reviewed one.
+func (c *conceptMapRepository) Propose(mappings []Mapping) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, mapping := range mappings {
+			stored, err := pairMapping(tx, pairKey(mapping))
+			if err != nil {
+				return fmt.Errorf("unable to read mappings: %w", err)
+			}
+
+			if stored != nil {
+				if stored.Status == StatusAccepted || stored.Status == StatusRejected {
+					continue
+				}
+				mapping.ID = stored.ID
+				mapping.CreatedAt = stored.CreatedAt
+			} else {
+				id, err := tx.Bucket(mappingsBucket).NextSequence()
+				if err != nil {
+					return err
+				}
+				mapping.ID = id
+				mapping.CreatedAt = now
+			}
+			mapping.Status = StatusProposed
+			mapping.UpdatedAt = now
+
+			if err := putMapping(tx, mapping); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Get implements ConceptMapRepository.
+func (c *conceptMapRepository) Get(id uint64) (*Mapping, error) {
+	var mapping Mapping
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(mappingsBucket).Get(itob(id))
+		if value == nil {
+			return ErrMappingNotFound
+		}
+		return json.Unmarshal(value, &mapping)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// Update implements ConceptMapRepository.
+func (c *conceptMapRepository) Update(mapping Mapping) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(mappingsBucket).Get(itob(mapping.ID)) == nil {
+			return ErrMappingNotFound
+		}
+
+		paired, err := pairMapping(tx, pairKey(mapping))
+		if err != nil {
+			return fmt.Errorf("unable to read mappings: %w", err)
+		}
+		if paired != nil && paired.ID != mapping.ID {
+			return fmt.Errorf("%w: %s %s and %s are mapping %d", ErrMappingConflict, mapping.NamasteType, mapping.NamasteCode, mapping.ICDCode, paired.ID)
+		}
+
+		mapping.UpdatedAt = time.Now()
+		return putMapping(tx, mapping)
+	})
+}
+
+// List implements ConceptMapRepository.
+func (c *conceptMapRepository) List() ([]Mapping, error) {
+	mappings := make([]Mapping, 0)
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(mappingsBucket).ForEach(func(_, v []byte) error {
+			var mapping Mapping
+			if err := json.Unmarshal(v, &mapping); err != nil {
+				return err
+			}
+			mappings = append(mappings, mapping)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mappings: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// FindByNamaste implements ConceptMapRepository.
+func (c *conceptMapRepository) FindByNamaste(code string) ([]Mapping, error) {
+	return c.find(namasteIndexBucket, code)
+}
+
+// FindByICD implements ConceptMapRepository.
+func (c *conceptMapRepository) FindByICD(code string) ([]Mapping, error) {
+	return c.find(icdIndexBucket, code)
+}
+
+// find returns the mappings indexed under code in the index bucket, in the
+// order they were first stored.
+func (c *conceptMapRepository) find(index []byte, code string) ([]Mapping, error) {
+	mappings := make([]Mapping, 0)
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(mappingsBucket)
+		prefix := []byte(code + "\x00")
+
+		cursor := tx.Bucket(index).Cursor()
+		for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+			value := bucket.Get(k[len(prefix):])
+			if value == nil {
+				continue
+			}
+
+			var mapping Mapping
+			if err := json.Unmarshal(value, &mapping); err != nil {
+				return err
+			}
+			mappings = append(mappings, mapping)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mappings: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// pairMapping returns the mapping stored for the pair with key, or nil if
+// there is none.
+func pairMapping(tx *bolt.Tx, key string) (*Mapping, error) {
+	id := tx.Bucket(pairsBucket).Get([]byte(key))
+	if id == nil {
+		return nil, nil
+	}
+
+	value := tx.Bucket(mappingsBucket).Get(id)
+	if value == nil {
+		return nil, nil
+	}
+
+	var mapping Mapping
+	if err := json.Unmarshal(value, &mapping); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// putMapping stores mapping, moving its index entries if its codes changed.
+func putMapping(tx *bolt.Tx, mapping Mapping) error {
+	bucket := tx.Bucket(mappingsBucket)
+
+	if value := bucket.Get(itob(mapping.ID)); value != nil {
+		var stored Mapping
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return err
+		}
+		if err := unindexMapping(tx, stored); err != nil {
+			return fmt.Errorf("unable to unindex mapping %d: %w", mapping.ID, err)
+		}
+	}
+
+	value, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+
+	if err := bucket.Put(itob(mapping.ID), value); err != nil {
+		return fmt.Errorf("unable to store mapping %d: %w", mapping.ID, err)
+	}
+	if err := indexMapping(tx, mapping); err != nil {
+		return fmt.Errorf("unable to index mapping %d: %w", mapping.ID, err)
+	}
+
+	return nil
+}
+
+func indexMapping(tx *bolt.Tx, mapping Mapping) error {
+	if err := tx.Bucket(pairsBucket).Put([]byte(pairKey(mapping)), itob(mapping.ID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(namasteIndexBucket).Put(indexKey(mapping.NamasteCode, mapping.ID), nil); err != nil {
+		return err
+	}
+	return tx.Bucket(icdIndexBucket).Put(indexKey(mapping.ICDCode, mapping.ID), nil)
+}
+
+// unindexMapping removes the index entries of mapping. The pair is left to
+// another mapping that was edited into it since.
+func unindexMapping(tx *bolt.Tx, mapping Mapping) error {
+	pairs := tx.Bucket(pairsBucket)
+	key := []byte(pairKey(mapping))
+	if bytes.Equal(pairs.Get(key), itob(mapping.ID)) {
+		if err := pairs.Delete(key); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(namasteIndexBucket).Delete(indexKey(mapping.NamasteCode, mapping.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket(icdIndexBucket).Delete(indexKey(mapping.ICDCode, mapping.ID))
+}
+
+func pairKey(m Mapping) string {
+	return m.NamasteType + "/" + m.NamasteCode + "|" + m.ICDCode
+}
+
+// indexKey is code, a NUL byte, then id, so that the keys of a code are
+// found by prefix and sort in insertion order.
+func indexKey(code string, id uint64) []byte {
+	return append([]byte(code+"\x00"), itob(id)...)
+}
+
+// itob encodes an id big-endian so that keys sort in insertion order.
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,208 @@
+package repository_test
+
+import (
+	"backend/internal/repository"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newConceptMap(t *testing.T, path string) repository.ConceptMapRepository {
+	t.Helper()
+
+	conceptMapRepository, err := repository.NewConceptMapRepository(path)
+	if err != nil {
+		t.Fatalf("NewConceptMapRepository: %v", err)
+	}
+	return conceptMapRepository
+}
+
+func mappingIDs(mappings []repository.Mapping) []uint64 {
+	ids := make([]uint64, 0, len(mappings))
+	for _, mapping := range mappings {
+		ids = append(ids, mapping.ID)
+	}
+	return ids
+}
+
+// testMappings pair jvaraH with two ICD-11 codes, and a siddha code that
+// shares its code with SA80.
+var testMappings = []repository.Mapping{
+	{NamasteType: "ayurveda", NamasteCode: "AAA-1", ICDCode: "SA80", Equivalence: "equivalent"},
+	{NamasteType: "ayurveda", NamasteCode: "AAA-1", ICDCode: "MG26", Equivalence: "wider"},
+	{NamasteType: "siddha", NamasteCode: "AAA-1", ICDCode: "SA80", Equivalence: "equivalent"},
+	{NamasteType: "unani", NamasteCode: "AAA-10", ICDCode: "SA81", Equivalence: "relatedto"},
+}
+
+func TestConceptMapFind(t *testing.T) {
+	conceptMapRepository := newConceptMap(t, filepath.Join(t.TempDir(), "conceptmap.db"))
+
+	if err := conceptMapRepository.Propose(testMappings); err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+
+	mappings, err := conceptMapRepository.FindByNamaste("AAA-1")
+	if err != nil {
+		t.Fatalf("FindByNamaste: %v", err)
+	}
+	// AAA-10 starts with AAA-1 but is another code.
+	if ids := mappingIDs(mappings); !slices.Equal(ids, []uint64{1, 2, 3}) {
+		t.Errorf("FindByNamaste(AAA-1) = %v, want [1 2 3]", ids)
+	}
+
+	mappings, err = conceptMapRepository.FindByICD("SA80")
+	if err != nil {
+		t.Fatalf("FindByICD: %v", err)
+	}
+	if ids := mappingIDs(mappings); !slices.Equal(ids, []uint64{1, 3}) {
+		t.Errorf("FindByICD(SA80) = %v, want [1 3]", ids)
+	}
+
+	if mappings, err := conceptMapRepository.FindByICD("1A00"); err != nil || len(mappings) != 0 {
+		t.Errorf("FindByICD(1A00) = %v, %v, want nothing", mappings, err)
+	}
+}
+
+func TestConceptMapPropose(t *testing.T) {
+	conceptMapRepository := newConceptMap(t, filepath.Join(t.TempDir(), "conceptmap.db"))
+
+	if err := conceptMapRepository.Propose(testMappings[:2]); err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+
+	accepted, err := conceptMapRepository.Get(1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	accepted.Status = repository.StatusAccepted
+	if err := conceptMapRepository.Update(*accepted); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// Proposing the same pairs again refreshes the proposed mapping and
+	// leaves the accepted one alone.
+	again := slices.Clone(testMappings[:2])
+	again[0].Equivalence = "narrower"
+	again[1].Equivalence = "relatedto"
+	if err := conceptMapRepository.Propose(again); err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+
+	mappings, err := conceptMapRepository.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(mappings) != 2 {
+		t.Fatalf("List returned %d mappings, want 2", len(mappings))
+	}
+	if mappings[0].Status != repository.StatusAccepted || mappings[0].Equivalence != "equivalent" {
+		t.Errorf("accepted mapping became %+v", mappings[0])
+	}
+	if mappings[1].Status != repository.StatusProposed || mappings[1].Equivalence != "relatedto" {
+		t.Errorf("proposed mapping became %+v", mappings[1])
+	}
+}
+
+func TestConceptMapUpdateMovesIndexes(t *testing.T) {
+	conceptMapRepository := newConceptMap(t, filepath.Join(t.TempDir(), "conceptmap.db"))
+
+	if err := conceptMapRepository.Propose(testMappings[:1]); err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+
+	mapping, err := conceptMapRepository.Get(1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	mapping.ICDCode = "SA81"
+	if err := conceptMapRepository.Update(*mapping); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if mappings, _ := conceptMapRepository.FindByICD("SA80"); len(mappings) != 0 {
+		t.Errorf("FindByICD(SA80) = %v after the edit, want nothing", mappings)
+	}
+	if mappings, _ := conceptMapRepository.FindByICD("SA81"); !slices.Equal(mappingIDs(mappings), []uint64{1}) {
+		t.Errorf("FindByICD(SA81) = %v after the edit, want mapping 1", mappings)
+	}
+
+	// The old pair is free to be proposed again, and the new one is taken.
+	if err := conceptMapRepository.Propose([]repository.Mapping{testMappings[0], {NamasteType: "ayurveda", NamasteCode: "AAA-1", ICDCode: "SA81"}}); err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+	if mappings, _ := conceptMapRepository.FindByNamaste("AAA-1"); !slices.Equal(mappingIDs(mappings), []uint64{1, 2}) {
+		t.Errorf("FindByNamaste(AAA-1) = %v, want mappings 1 and 2", mappingIDs(mappings))
+	}
+
+	// Mapping 1 cannot take back the pair mapping 2 now holds.
+	mapping.ICDCode = "SA80"
+	if err := conceptMapRepository.Update(*mapping); !errors.Is(err, repository.ErrMappingConflict) {
+		t.Errorf("Update into the pair of mapping 2 returned %v, want ErrMappingConflict", err)
+	}
+	if mappings, _ := conceptMapRepository.FindByICD("SA80"); !slices.Equal(mappingIDs(mappings), []uint64{2}) {
+		t.Errorf("FindByICD(SA80) = %v after the conflict, want mapping 2", mappingIDs(mappings))
+	}
+
+	if err := conceptMapRepository.Update(repository.Mapping{ID: 99}); !errors.Is(err, repository.ErrMappingNotFound) {
+		t.Errorf("Update of an unknown mapping returned %v, want ErrMappingNotFound", err)
+	}
+}
+
+func TestConceptMapIndexesExistingStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conceptmap.db")
+
+	// A store written before the indexes holds only the mappings bucket.
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("mappings"))
+		if err != nil {
+			return err
+		}
+		for i, mapping := range testMappings {
+			mapping.ID = uint64(i + 1)
+			mapping.Status = repository.StatusProposed
+			value, err := json.Marshal(mapping)
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, mapping.ID)
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+		return bucket.SetSequence(uint64(len(testMappings)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conceptMapRepository := newConceptMap(t, path)
+
+	if mappings, _ := conceptMapRepository.FindByNamaste("AAA-1"); !slices.Equal(mappingIDs(mappings), []uint64{1, 2, 3}) {
+		t.Errorf("FindByNamaste(AAA-1) = %v, want [1 2 3]", mappingIDs(mappings))
+	}
+	if mappings, _ := conceptMapRepository.FindByICD("SA81"); !slices.Equal(mappingIDs(mappings), []uint64{4}) {
+		t.Errorf("FindByICD(SA81) = %v, want [4]", mappingIDs(mappings))
+	}
+
+	// Stored pairs are found again rather than stored twice.
+	if err := conceptMapRepository.Propose(testMappings); err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+	if mappings, _ := conceptMapRepository.List(); len(mappings) != len(testMappings) {
+		t.Errorf("List returned %d mappings, want %d", len(mappings), len(testMappings))
+	}
+}
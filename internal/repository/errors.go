@@ -0,0 +1,33 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a code does not exist in the code system.
+	ErrNotFound = errors.New("code not found")
+	// ErrMappingNotFound is returned when no mapping has the requested id.
+	ErrMappingNotFound = errors.New("mapping not found")
+	// ErrMappingConflict is returned when an update would give a mapping the
+	// pair of another one.
+	ErrMappingConflict = errors.New("pair already mapped")
+)
+
+// UpstreamError is returned when a remote API a repository depends on fails
+// or answers with something unusable.
+type UpstreamError struct {
+	Service string
+	Err     error
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Service + ": " + e.Err.Error()
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// whoError wraps a failure of the WHO ICD API.
+func whoError(err error) error {
+	return &UpstreamError{Service: "WHO ICD API", Err: err}
+}
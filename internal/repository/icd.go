@@ -2,186 +2,336 @@ package repository
 
 import (
 	"backend/cmd/web/dto"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"path"
-	"strconv"
+	"slices"
+	"strings"
 	"sync"
-	"time"
 )
 
 type ICDRepository interface {
-	Find(input string) (*ICDMatches, error)
-	List(size int) ([]ICDMatch, error)
+	Find(ctx context.Context, input string) (*ICDMatches, error)
+	List(ctx context.Context, size int) ([]ICDMatch, error)
+	Get(ctx context.Context, code string) (*ICDMatch, error)
+	Search(ctx context.Context, input string, offset int, size int) ([]ICDMatch, int, error)
+	// Chapters returns a view of the repository restricted to the given
+	// chapters, such as TM2Chapters.
+	Chapters(chapters []string) ICDRepository
+	// Edition returns the edition the repository serves.
+	Edition() ICDEdition
+	// WithEdition returns a view of the repository serving edition, whose
+	// empty fields are those of the repository. A language the release has
+	// not been translated into falls back to the language of the repository,
+	// which the view reports.
+	WithEdition(ctx context.Context, edition ICDEdition) (ICDRepository, error)
 }
 
-type icdRepository struct {
-	client       *http.Client
-	clientID     string
-	clientSecret string
+// WHOEndpoint is where the WHO API is reached, and the credentials it is
+// called with. The URLs can point to a stand-in of the API, such as the one
+// of package icdapitest.
+type WHOEndpoint struct {
+	// APIURL is the base of the ICD API URLs, without a trailing slash.
+	APIURL string
+	// TokenURL is the OAuth2 endpoint issuing the access tokens.
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+}
+
+// DefaultWHOEndpoint holds the URLs of the WHO API, used for the URLs of an
+// endpoint that are not set.
+var DefaultWHOEndpoint = WHOEndpoint{
+	APIURL:   "https://id.who.int",
+	TokenURL: "https://icdaccessmanagement.who.int/connect/token",
+}
 
-	mu          sync.Mutex
-	accessToken string
-	expiry      time.Time
+type icdRepository struct {
+	client    *whoClient
+	cache     ICDCache
+	languages *icdLanguages
+	apiURL    string
+	// tokens is shared by every view of the repository.
+	tokens tokenSource
+
+	edition ICDEdition
+
+	// chapters restricts the repository to these chapters. Empty means the
+	// whole linearization.
+	chapters []string
 }
 
 type ICDMatch struct {
 	ID   string
 	Name string
 	Desc string
+
+	// Kind, Parent and Children describe the place of the entity in the
+	// hierarchy. They are only filled in by List.
+	Kind     string
+	Parent   string
+	Children []string
 }
 
 type ICDMatches struct {
 	Matches []ICDMatch
+	// Undefined holds the codes of the matches whose definition could not
+	// be fetched, which are left without one.
+	Undefined []string
 }
 
-func (i *icdRepository) fetchDescription(id string, ch chan string) {
-	descriptionURL := "https://id.who.int/icd/release/11/2025-01/mms/" + id
+// description is the definition of an entity, or the error it could not be
+// fetched with.
+type description struct {
+	value string
+	err   error
+}
 
-	req, err := http.NewRequest("GET", descriptionURL, nil)
-	if err != nil {
-		log.Println("Error: " + err.Error())
-		ch <- ""
+// fetchDescription sends the definition of the entity with id to ch.
+func (i *icdRepository) fetchDescription(ctx context.Context, id string, ch chan<- description) {
+	var entity dto.EntityResponse
+	if err := i.getJSON(ctx, i.releaseURL(id), &entity); err != nil {
+		ch <- description{err: fmt.Errorf("unable to fetch description for id %s: %w", id, err)}
 		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+i.accessToken)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("API-Version", "v2")
-	req.Header.Set("Accept-Language", "en")
+	ch <- description{value: entity.Definition.Value}
+}
 
-	resp, err := i.client.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		if err != nil {
-			log.Println("Error: " + err.Error())
-		} else {
-			log.Println("Error: " + resp.Status)
-		}
-		ch <- ""
-		return
+// List implements ICDRepository. The linearization is walked depth first, in
+// tabulation order, fetching entities only while there is room for them.
+func (i *icdRepository) List(ctx context.Context, size int) ([]ICDMatch, error) {
+	if size <= 0 {
+		return make([]ICDMatch, 0), nil
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error: " + err.Error())
-		ch <- ""
-		return
+	var root dto.EntityResponse
+	if err := i.getJSON(ctx, i.releaseURL(""), &root); err != nil {
+		return nil, err
 	}
 
-	type definition struct {
-		Value string `json:"@value"`
+	walk := icdWalk{
+		repository: i,
+		size:       size,
+		seen:       make(map[string]bool),
+		matches:    make([]ICDMatch, 0, min(size, 1024)),
 	}
 
-	var descriptionResponse struct {
-		Definition definition `json:"definition"`
+	if err := walk.children(ctx, root.ID, -1, i.chapterIDs(root.Child)); err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal(body, &descriptionResponse); err != nil {
-		log.Println("Error: " + err.Error())
-		ch <- ""
-		return
+	return walk.matches, nil
+}
+
+// icdChapterOrder lists the chapters of the MMS in the order the
+// linearization lists them.
+var icdChapterOrder = append(append(slices.Clone(BiomedicineChapters), TM2Chapters...), "V", "X")
+
+// chapterIDs returns the ids of the chapters of the repository among
+// childIDs, the children of the root, by their place when the root lists
+// every chapter and all of them otherwise.
+func (i *icdRepository) chapterIDs(childIDs []string) []string {
+	if len(i.chapters) == 0 || len(childIDs) != len(icdChapterOrder) {
+		return childIDs
 	}
 
-	log.Println("Found description for id " + id + ": " + descriptionResponse.Definition.Value)
-	ch <- descriptionResponse.Definition.Value
+	ids := make([]string, 0, len(i.chapters))
+	for idx, chapter := range icdChapterOrder {
+		if slices.Contains(i.chapters, chapter) {
+			ids = append(ids, childIDs[idx])
+		}
+	}
+
+	return ids
+}
+
+// icdWalk is the state of a List walk over the linearization.
+type icdWalk struct {
+	repository *icdRepository
+	size       int
+	seen       map[string]bool
+	matches    []ICDMatch
 }
 
-func (i *icdRepository) List(size int) ([]ICDMatch, error) {
-	const searchURL = "https://id.who.int/icd/release/11/2025-01/mms/search"
-	const numWorkers = 2 // Control the number of parallel requests
+// children adds the entities under parentID, and in turn their children,
+// after the match at index parent. Siblings are fetched a batch at a time,
+// no more than there is room left for.
+func (w *icdWalk) children(ctx context.Context, parentID string, parent int, childIDs []string) error {
+	for len(childIDs) > 0 {
+		room := w.size - len(w.matches)
+		if room <= 0 {
+			return nil
+		}
 
-	if err := i.ensureToken(); err != nil {
-		return nil, err
+		batch := childIDs[:min(room, len(childIDs))]
+		childIDs = childIDs[len(batch):]
+
+		entities, err := w.fetch(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		if err := w.add(ctx, parentID, parent, entities); err != nil {
+			return err
+		}
 	}
 
-	var allMatches []ICDMatch
-	alphabet := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	return nil
+}
+
+// fetch fetches the entities with ids in parallel.
+func (w *icdWalk) fetch(ctx context.Context, ids []string) ([]dto.EntityResponse, error) {
+	const numWorkers = 4 // Control the number of parallel requests
 
-	// Channels for managing the parallel search
-	letters := make(chan string, len(alphabet))
-	results := make(chan []ICDMatch, len(alphabet))
-	errs := make(chan error, len(alphabet))
+	entities := make([]dto.EntityResponse, len(ids))
+	errs := make(chan error, len(ids))
+	sem := make(chan struct{}, numWorkers)
 
-	// Start the worker goroutines
-	for range numWorkers {
+	var wg sync.WaitGroup
+	for idx, id := range ids {
+		wg.Add(1)
 		go func() {
-			for char := range letters {
-				// Each worker handles one letter at a time
-				matches, err := i.fetchMatchesForLetter(searchURL, char, size)
-				if err != nil {
-					errs <- err
-					continue
-				}
-				results <- matches
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			entityURL, err := w.repository.entityURL(id)
+			if err == nil {
+				err = w.repository.getJSON(ctx, entityURL, &entities[idx])
+			}
+			if err != nil {
+				errs <- err
 			}
 		}()
 	}
+	wg.Wait()
+	close(errs)
 
-	// Send letters to the workers
-	for _, char := range alphabet {
-		letters <- string(char)
+	if err := <-errs; err != nil {
+		return nil, err
 	}
-	close(letters) // No more letters will be sent
 
-	// Collect results from the workers
-	for range alphabet {
-		select {
-		case res := <-results:
-			allMatches = append(allMatches, res...)
-			// If we have enough results, we can stop
-			if len(allMatches) >= size {
-				return allMatches[:size], nil
-			}
-		case err := <-errs:
-			// Handle the first error and return
-			return nil, err
+	return entities, nil
+}
+
+// add adds the fetched entities under parentID, and walks their children.
+func (w *icdWalk) add(ctx context.Context, parentID string, parent int, entities []dto.EntityResponse) error {
+	for _, entity := range entities {
+		if len(w.matches) >= w.size {
+			return nil
+		}
+
+		key := entity.Code
+		if key == "" {
+			key = entity.BlockID
+		}
+
+		if parent < 0 && len(w.repository.chapters) > 0 && !slices.Contains(w.repository.chapters, key) {
+			continue
+		}
+		if parent >= 0 && len(w.repository.chapters) > 0 && inModuleI(key) {
+			continue
+		}
+
+		if parent >= 0 {
+			w.matches[parent].Children = append(w.matches[parent].Children, key)
+		}
+
+		if w.seen[entity.ID] || (len(entity.Parent) > 0 && entity.Parent[0] != parentID) {
+			continue
+		}
+		w.seen[entity.ID] = true
+
+		match := ICDMatch{
+			ID:   key,
+			Name: entity.Title.Value,
+			Desc: entity.Definition.Value,
+			Kind: entity.ClassKind,
+		}
+		if parent >= 0 {
+			match.Parent = w.matches[parent].ID
+		}
+		w.matches = append(w.matches, match)
+
+		if err := w.children(ctx, entity.ID, len(w.matches)-1, entity.Child); err != nil {
+			return err
 		}
 	}
 
-	return allMatches, nil
+	return nil
 }
 
-// fetchMatchesForLetter is a helper function to perform the actual API call for a single letter
-func (i *icdRepository) fetchMatchesForLetter(searchURL, char string, size int) ([]ICDMatch, error) {
-	needed := size // We don't know exactly how many we need, so we ask for the full size
+// search runs a full text search against the linearization, within the
+// chapters of the repository.
+func (i *icdRepository) search(ctx context.Context, input string) (*dto.SearchResponse, error) {
+	query := "?q=" + url.QueryEscape(input) + "&subtreeFilterUsesFoundationDescendants=false&includeKeywordResult=false&useFlexisearch=false&flatResults=true&highlightingEnabled=false&medicalCodingMode=false&propertiesToBeSearched=Title%2CFullySpecifiedName%2CDefinition%2CIndexTerm"
+	if len(i.chapters) > 0 {
+		query += "&chapterFilter=" + url.QueryEscape(strings.Join(i.chapters, ";"))
+	}
 
-	req, err := http.NewRequest("GET", searchURL+"?q="+url.QueryEscape(char)+"&maxList="+strconv.Itoa(needed)+"&flatResults=true&highlightingEnabled=false", nil)
-	if err != nil {
+	var response dto.SearchResponse
+	if err := i.getJSON(ctx, i.releaseURL("search")+query, &response); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+i.accessToken)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("API-Version", "v2")
-	req.Header.Set("Accept-Language", "en")
 
-	resp, err := i.client.Do(req)
+	// The chapter filter cannot tell Module I from TM2, so its codes are
+	// dropped here.
+	if len(i.chapters) > 0 {
+		response.DestinationEntities = slices.DeleteFunc(response.DestinationEntities, func(entity dto.DestinationEntity) bool {
+			return inModuleI(entity.TheCode)
+		})
+	}
+
+	return &response, nil
+}
+
+// Search implements ICDRepository. It returns one page of the search results
+// without their definitions, along with the total number of results.
+func (i *icdRepository) Search(ctx context.Context, input string, offset int, size int) ([]ICDMatch, int, error) {
+	response, err := i.search(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status for search with '%s': %s", char, resp.Status)
+	total := len(response.DestinationEntities)
+	start := min(offset, total)
+	end := min(start+size, total)
+
+	matches := make([]ICDMatch, 0, end-start)
+	for _, entity := range response.DestinationEntities[start:end] {
+		matches = append(matches, ICDMatch{
+			ID:   entity.TheCode,
+			Name: entity.Title,
+		})
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return matches, total, nil
+}
+
+// Find implements ICDRepository. The definitions missing from the search
+// results are fetched in parallel. The matches are still useful without
+// them, so the codes whose definition cannot be fetched are only reported
+// as undefined.
+func (i *icdRepository) Find(ctx context.Context, input string) (*ICDMatches, error) {
+	response, err := i.search(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("read body failed: %w", err)
+		return nil, err
 	}
 
-	var response dto.SearchResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("unmarshal failed: %w", err)
-	}
+	matches := make([]ICDMatch, 0, 5)
+	channels := make(map[int]chan description)
 
-	var matches []ICDMatch
-	channels := make(map[int]chan string)
 	for idx, entity := range response.DestinationEntities {
+		if idx == 5 {
+			break
+		}
 
 		var definition string
 		for _, pv := range entity.MatchingPVs {
@@ -192,160 +342,276 @@ func (i *icdRepository) fetchMatchesForLetter(searchURL, char string, size int)
 		}
 
 		if definition == "" {
-			ch := make(chan string)
+			ch := make(chan description, 1)
 			channels[idx] = ch
 			parsedURL, err := url.Parse(entity.ID)
 			if err != nil {
-				log.Fatalln("Invalid id: %w", err)
+				ch <- description{err: fmt.Errorf("invalid id %q of code %s: %w", entity.ID, entity.TheCode, err)}
+			} else {
+				log.Printf("Fetching description for code (%s) id: %s\n", entity.TheCode, entity.ID)
+				go i.fetchDescription(ctx, path.Base(parsedURL.Path), ch)
 			}
-			id := path.Base(parsedURL.Path)
-
-			log.Printf("Fetching description for code (%s) id: %s\n", entity.TheCode, entity.ID)
-			go i.fetchDescription(id, ch)
 		}
+
 		matches = append(matches, ICDMatch{
 			ID:   entity.TheCode,
 			Name: entity.Title,
+			Desc: definition,
 		})
 	}
 
+	var undefined []string
 	for idx, ch := range channels {
-		matches[idx].Desc = <-ch
+		desc := <-ch
+		if desc.err != nil {
+			log.Println("Error: " + desc.err.Error())
+			whoMetrics.Add("missing_definitions", 1)
+			undefined = append(undefined, matches[idx].ID)
+			continue
+		}
+		matches[idx].Desc = desc.value
 	}
+	slices.Sort(undefined)
 
-	return matches, nil
+	return &ICDMatches{
+		Matches:   matches,
+		Undefined: undefined,
+	}, nil
 }
 
-func (i *icdRepository) Find(input string) (*ICDMatches, error) {
-	const searchURL = "https://id.who.int/icd/release/11/2025-01/mms/search"
+// Get implements ICDRepository. The code is resolved to its stem entity
+// through the codeinfo endpoint, which is then fetched for its title and
+// definition.
+func (i *icdRepository) Get(ctx context.Context, code string) (*ICDMatch, error) {
+	if !inChapters(code, i.chapters) {
+		return nil, ErrNotFound
+	}
 
-	if err := i.ensureToken(); err != nil {
+	var codeInfo dto.CodeInfoResponse
+	if err := i.getJSON(ctx, i.releaseURL("codeinfo/"+url.PathEscape(code))+"?flexiblemode=false", &codeInfo); err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", searchURL+"?q="+url.QueryEscape(input)+"&subtreeFilterUsesFoundationDescendants=false&includeKeywordResult=false&useFlexisearch=false&flatResults=true&highlightingEnabled=false&medicalCodingMode=false&propertiesToBeSearched=Title%2CFullySpecifiedName%2CDefinition%2CIndexTerm", nil)
+	stemURL, err := i.entityURL(codeInfo.StemID)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+i.accessToken)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("API-Version", "v2")
-	req.Header.Set("Accept-Language", "en")
 
-	resp, err := i.client.Do(req)
+	var entity dto.EntityResponse
+	if err := i.getJSON(ctx, stemURL, &entity); err != nil {
+		return nil, err
+	}
+
+	return &ICDMatch{
+		ID:   codeInfo.Code,
+		Name: entity.Title.Value,
+		Desc: entity.Definition.Value,
+	}, nil
+}
+
+// entityURL turns the id of an entity, which is an http URI, into the URL
+// it can be fetched from.
+func (i *icdRepository) entityURL(id string) (string, error) {
+	parsedURL, err := url.Parse(id)
+	if err != nil {
+		return "", whoError(fmt.Errorf("invalid entity id %q: %w", id, err))
+	}
+
+	return i.apiURL + parsedURL.Path, nil
+}
+
+// releaseURL returns the URL of path within the release and linearization of
+// the repository, or of the linearization itself if path is empty.
+func (i *icdRepository) releaseURL(path string) string {
+	releaseURL := i.apiURL + "/icd/release/11/" + i.edition.Release + "/" + i.edition.Linearization
+	if path == "" {
+		return releaseURL
+	}
+
+	return releaseURL + "/" + path
+}
+
+// getJSON performs an authenticated GET against the ICD API and decodes the
+// response into v. A 404 is reported as ErrNotFound.
+func (i *icdRepository) getJSON(ctx context.Context, apiURL string, v any) error {
+	body, err := i.fetch(ctx, apiURL)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return whoError(fmt.Errorf("unmarshal failed: %w", err))
+	}
+
+	return nil
+}
+
+// fetch returns the body of an authenticated GET against the ICD API in the
+// language of the repository. The responses of a release are the same for
+// everyone and never change, so they are served from the cache when it has
+// them, without a token.
+func (i *icdRepository) fetch(ctx context.Context, apiURL string) ([]byte, error) {
+	key := i.edition.Release + "|" + i.edition.Language + "|" + apiURL
+	if i.cache != nil {
+		if body, ok := i.cache.Get(key); ok {
+			return body, nil
+		}
+	}
+
+	resp, err := i.get(ctx, apiURL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
+		return nil, whoError(fmt.Errorf("bad status: %s", resp.Status))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read body failed: %w", err)
+		return nil, whoError(fmt.Errorf("read body failed: %w", err))
 	}
 
-	var response dto.SearchResponse
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("unmarshal failed: %w", err)
+	if i.cache != nil {
+		i.cache.Put(key, body)
 	}
 
-	matches := make([]ICDMatch, 0, 5)
-	channels := make(map[int]chan string)
+	return body, nil
+}
 
-	for idx, entity := range response.DestinationEntities {
-		if idx == 5 {
-			break
+// get sends an authenticated GET for apiURL. WHO can revoke a token before
+// it expires, so a request it rejects is sent once more with a new token.
+func (i *icdRepository) get(ctx context.Context, apiURL string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := i.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
 		}
 
-		var definition string
-		for _, pv := range entity.MatchingPVs {
-			if pv.PropertyID == "Definition" {
-				definition = pv.Label
-				break
-			}
+		req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+		if err != nil {
+			return nil, err
 		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("API-Version", "v2")
+		req.Header.Set("Accept-Language", i.edition.Language)
 
-		if definition == "" {
-			ch := make(chan string)
-			channels[idx] = ch
-			parsedURL, err := url.Parse(entity.ID)
-			if err != nil {
-				log.Fatalln("Invalid id: %w", err)
-			}
-			id := path.Base(parsedURL.Path)
+		resp, err := i.client.do(req)
+		if err != nil {
+			return nil, err
+		}
 
-			log.Printf("Fetching description for code (%s) id: %s\n", entity.TheCode, entity.ID)
-			go i.fetchDescription(id, ch)
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
 		}
 
-		matches = append(matches, ICDMatch{
-			ID:   entity.TheCode,
-			Name: entity.Title,
-		})
+		resp.Body.Close()
+		i.tokens.Invalidate(token)
 	}
+}
 
-	for idx, ch := range channels {
-		matches[idx].Desc = <-ch
+// NewICDRepository returns a repository serving edition from the ICD API at
+// endpoint. Unset fields default to DefaultWHOEndpoint and DefaultICDEdition,
+// and responses are cached unless cache is nil.
+func NewICDRepository(client *http.Client, endpoint WHOEndpoint, options WHOClientOptions, cache ICDCache, edition ICDEdition) (ICDRepository, error) {
+	if endpoint.APIURL == "" {
+		endpoint.APIURL = DefaultWHOEndpoint.APIURL
+	}
+	if endpoint.TokenURL == "" {
+		endpoint.TokenURL = DefaultWHOEndpoint.TokenURL
 	}
 
-	return &ICDMatches{
-		Matches: matches,
-	}, nil
-}
+	edition = edition.Or(DefaultICDEdition)
+	if err := edition.Validate(); err != nil {
+		return nil, err
+	}
+
+	whoClient := newWHOClient(client, options)
 
-func NewICDRepository(client *http.Client, clientID string, clientSecret string) ICDRepository {
 	return &icdRepository{
-		client:       client,
-		clientID:     clientID,
-		clientSecret: clientSecret,
-	}
+		client:    whoClient,
+		cache:     cache,
+		languages: &icdLanguages{languages: make(map[string][]string)},
+		apiURL:    strings.TrimSuffix(endpoint.APIURL, "/"),
+		tokens:    newWHOTokenSource(whoClient, endpoint),
+		edition:   edition,
+	}, nil
 }
 
-func (i *icdRepository) getToken() error {
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("client_id", i.clientID)
-	data.Set("client_secret", i.clientSecret)
-	data.Set("scope", "icdapi_access")
+// Chapters implements ICDRepository.
+func (i *icdRepository) Chapters(chapters []string) ICDRepository {
+	view := *i
+	view.chapters = chapters
+	return &view
+}
 
-	const tokenURL = "https://icdaccessmanagement.who.int/connect/token"
+// Edition implements ICDRepository.
+func (i *icdRepository) Edition() ICDEdition {
+	return i.edition
+}
 
-	resp, err := http.PostForm(tokenURL, data)
-	if err != nil {
-		return fmt.Errorf("token request failed: %w", err)
+// WithEdition implements ICDRepository. The release is looked up the first
+// time it is asked for, which tells whether it exists and which languages it
+// has been translated into.
+func (i *icdRepository) WithEdition(ctx context.Context, edition ICDEdition) (ICDRepository, error) {
+	edition = edition.Or(i.edition)
+	if err := edition.Validate(); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad token response: %s", resp.Status)
+	if edition == i.edition {
+		return i, nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	languages, err := i.availableLanguages(ctx, edition)
 	if err != nil {
-		return fmt.Errorf("read body failed: %w", err)
+		return nil, err
 	}
 
-	var tokenResp dto.TokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return fmt.Errorf("decode token failed: %w", err)
+	// WHO does not list the languages of every release, in which case the
+	// language is asked for anyway.
+	if len(languages) > 0 && !slices.Contains(languages, edition.Language) {
+		edition.Language = i.edition.Language
 	}
 
-	i.accessToken = tokenResp.AccessToken
-	i.expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn-60) * time.Second)
-	return nil
+	view := *i
+	view.edition = edition
+	return &view, nil
 }
 
-func (i *icdRepository) ensureToken() error {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+// availableLanguages returns the languages the release of edition has been
+// translated into, from the root of its linearization.
+func (i *icdRepository) availableLanguages(ctx context.Context, edition ICDEdition) ([]string, error) {
+	key := edition.Release + "/" + edition.Linearization
 
-	if time.Now().After(i.expiry) || i.accessToken == "" {
-		return i.getToken()
+	i.languages.mu.Lock()
+	languages, ok := i.languages.languages[key]
+	i.languages.mu.Unlock()
+	if ok {
+		return languages, nil
 	}
 
-	return nil
+	// The root is fetched in the language of the repository, which the
+	// release is more likely to have.
+	probe := *i
+	probe.edition = ICDEdition{Release: edition.Release, Linearization: edition.Linearization, Language: i.edition.Language}
+
+	var root dto.EntityResponse
+	err := probe.getJSON(ctx, probe.releaseURL(""), &root)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: WHO has no release %s of %s", ErrInvalidEdition, edition.Release, edition.Linearization)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	i.languages.mu.Lock()
+	i.languages.languages[key] = root.AvailableLanguages
+	i.languages.mu.Unlock()
+
+	return root.AvailableLanguages, nil
 }
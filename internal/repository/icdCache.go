@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	cacheEntriesBucket = []byte("entries")
+	cacheOrderBucket   = []byte("order")
+	cacheMetaBucket    = []byte("meta")
+	cacheSizeKey       = []byte("size")
+)
+
+// cacheMetrics counts the hits and misses of the ICD cache.
+var cacheMetrics = expvar.NewMap("icd_cache")
+
+// ICDCache keeps responses of the WHO API across restarts. A release of
+// ICD-11 never changes, so its responses can be kept for long.
+type ICDCache interface {
+	// Get returns the value stored under key, unless it has expired.
+	Get(key string) ([]byte, bool)
+	// Put stores value under key, evicting the oldest values to stay within
+	// the size limit.
+	Put(key string, value []byte)
+}
+
+// ICDCacheOptions limit what an ICDCache keeps.
+type ICDCacheOptions struct {
+	// TTL is how long a value is served after it is stored.
+	TTL time.Duration
+	// MaxBytes bounds the total size of the stored values.
+	MaxBytes int64
+}
+
+// DefaultICDCacheOptions are used for the options that are not set.
+var DefaultICDCacheOptions = ICDCacheOptions{
+	TTL:      30 * 24 * time.Hour,
+	MaxBytes: 256 << 20,
+}
+
+// icdCache stores each value in the entries bucket with the time it was
+// stored, and indexes it in the order bucket by that time so the oldest
+// values are found first for eviction.
+type icdCache struct {
+	db      *bolt.DB
+	options ICDCacheOptions
+}
+
+func NewICDCache(path string, options ICDCacheOptions) (ICDCache, error) {
+	if options.TTL <= 0 {
+		options.TTL = DefaultICDCacheOptions.TTL
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultICDCacheOptions.MaxBytes
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ICD cache: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{cacheEntriesBucket, cacheOrderBucket, cacheMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ICD cache buckets: %w", err)
+	}
+
+	return &icdCache{db: db, options: options}, nil
+}
+
+// Get implements ICDCache.
+func (c *icdCache) Get(key string) ([]byte, bool) {
+	var value []byte
+	var storedAt time.Time
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		entry := tx.Bucket(cacheEntriesBucket).Get([]byte(key))
+		if len(entry) < 8 {
+			return nil
+		}
+
+		storedAt = time.Unix(0, int64(binary.BigEndian.Uint64(entry)))
+		value = bytes.Clone(entry[8:])
+		return nil
+	})
+	if err != nil {
+		log.Println("Error: unable to read ICD cache: " + err.Error())
+	}
+
+	if value == nil || time.Since(storedAt) > c.options.TTL {
+		cacheMetrics.Add("misses", 1)
+		return nil, false
+	}
+
+	cacheMetrics.Add("hits", 1)
+	return value, true
+}
+
+// Put implements ICDCache. The values fetched in parallel, such as the
+// entities of a List, are written in a single batched transaction rather
+// than one each. Failing to store a value only costs a later request, so it
+// is logged rather than returned.
+func (c *icdCache) Put(key string, value []byte) {
+	if int64(len(value)) > c.options.MaxBytes {
+		return
+	}
+
+	// A batch may run the function again, which writes the same value.
+	err := c.db.Batch(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(cacheEntriesBucket)
+		order := tx.Bucket(cacheOrderBucket)
+		meta := tx.Bucket(cacheMetaBucket)
+
+		size := int64(0)
+		if stored := meta.Get(cacheSizeKey); len(stored) == 8 {
+			size = int64(binary.BigEndian.Uint64(stored))
+		}
+
+		if old := entries.Get([]byte(key)); len(old) >= 8 {
+			if err := order.Delete(orderKey(old[:8], key)); err != nil {
+				return err
+			}
+			size -= int64(len(old) - 8)
+		}
+
+		now := make([]byte, 8)
+		binary.BigEndian.PutUint64(now, uint64(time.Now().UnixNano()))
+
+		if err := entries.Put([]byte(key), append(now, value...)); err != nil {
+			return err
+		}
+		if err := order.Put(orderKey(now, key), nil); err != nil {
+			return err
+		}
+		size += int64(len(value))
+
+		// The oldest values go first: expired ones, then as many as needed
+		// to fit the new value.
+		expiry := uint64(time.Now().Add(-c.options.TTL).UnixNano())
+		var evicted [][]byte
+		cursor := order.Cursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			if size <= c.options.MaxBytes && binary.BigEndian.Uint64(k[:8]) >= expiry {
+				break
+			}
+
+			evicted = append(evicted, bytes.Clone(k))
+			if entry := entries.Get(k[8:]); entry != nil {
+				size -= int64(len(entry) - 8)
+			}
+		}
+
+		// Deleting while iterating would skip keys, so it is done after.
+		for _, k := range evicted {
+			if err := entries.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := order.Delete(k); err != nil {
+				return err
+			}
+		}
+		cacheMetrics.Add("evictions", int64(len(evicted)))
+
+		stored := make([]byte, 8)
+		binary.BigEndian.PutUint64(stored, uint64(max(size, 0)))
+		return meta.Put(cacheSizeKey, stored)
+	})
+	if err != nil {
+		log.Println("Error: unable to write ICD cache: " + err.Error())
+	}
+}
+
+// orderKey is the key of a value in the order bucket: the time it was
+// stored followed by its key.
+func orderKey(storedAt []byte, key string) []byte {
+	return append(bytes.Clone(storedAt), key...)
+}
@@ -0,0 +1,95 @@
+package repository_test
+
+import (
+	"backend/internal/repository"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newCache(t *testing.T, options repository.ICDCacheOptions) repository.ICDCache {
+	t.Helper()
+
+	cache, err := repository.NewICDCache(filepath.Join(t.TempDir(), "icd-cache.db"), options)
+	if err != nil {
+		t.Fatalf("NewICDCache: %v", err)
+	}
+	return cache
+}
+
+func TestICDCacheExpires(t *testing.T) {
+	cache := newCache(t, repository.ICDCacheOptions{TTL: 50 * time.Millisecond})
+
+	cache.Put("1A00", []byte("Cholera"))
+	if value, ok := cache.Get("1A00"); !ok || string(value) != "Cholera" {
+		t.Fatalf("Get = %q, %v, want Cholera", value, ok)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if value, ok := cache.Get("1A00"); ok {
+		t.Errorf("Get after the TTL = %q, want a miss", value)
+	}
+
+	// Storing the value again serves it anew.
+	cache.Put("1A00", []byte("Cholera"))
+	if _, ok := cache.Get("1A00"); !ok {
+		t.Error("Get of a stored again value missed")
+	}
+}
+
+func TestICDCacheEvicts(t *testing.T) {
+	cache := newCache(t, repository.ICDCacheOptions{MaxBytes: 10})
+
+	cache.Put("a", []byte("aaaa"))
+	cache.Put("b", []byte("bbbb"))
+	// Storing a again replaces it rather than counting it twice.
+	cache.Put("a", []byte("AAAA"))
+	for key, want := range map[string]string{"a": "AAAA", "b": "bbbb"} {
+		if value, ok := cache.Get(key); !ok || string(value) != want {
+			t.Errorf("Get(%s) = %q, %v, want %s", key, value, ok, want)
+		}
+	}
+
+	// The oldest value, now b, makes room for c.
+	cache.Put("c", []byte("cccc"))
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	// A value larger than the cache is not stored at all.
+	cache.Put("d", bytes.Repeat([]byte("d"), 11))
+	if _, ok := cache.Get("d"); ok {
+		t.Error("value larger than MaxBytes stored")
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Error("c was evicted for a value that does not fit")
+	}
+}
+
+func TestICDCacheConcurrentPuts(t *testing.T) {
+	cache := newCache(t, repository.ICDCacheOptions{})
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Put(fmt.Sprint(i), []byte(fmt.Sprint("value ", i)))
+		}()
+	}
+	wg.Wait()
+
+	for i := range 50 {
+		if value, ok := cache.Get(fmt.Sprint(i)); !ok || string(value) != fmt.Sprint("value ", i) {
+			t.Errorf("Get(%d) = %q, %v", i, value, ok)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import "strings"
+
+var (
+	// TM2Chapters holds the chapter of the Traditional Medicine conditions,
+	// where the TM2 Ayurveda, Siddha and Unani disorders and patterns sit
+	// next to Module I. Only the TM2 codes of the chapter are in the set.
+	TM2Chapters = []string{"26"}
+	// BiomedicineChapters holds the chapters of biomedical conditions. The
+	// functioning (V) and extension code (X) chapters are in neither set.
+	BiomedicineChapters = []string{
+		"01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12", "13",
+		"14", "15", "16", "17", "18", "19", "20", "21", "22", "23", "24", "25",
+	}
+)
+
+// chapterPrefixes lists, in chapter order, the first character of the codes
+// of chapters 01 to 26. I and O are skipped to avoid confusion with 1 and 0.
+const chapterPrefixes = "123456789ABCDEFGHJKLMNPQRS"
+
+// ICDChapter returns the chapter of an ICD-11 MMS code, which is encoded by
+// its first character, or an empty string if the code is not valid.
+func ICDChapter(code string) string {
+	if code == "" {
+		return ""
+	}
+
+	prefix := strings.ToUpper(code[:1])
+	if prefix == "V" || prefix == "X" {
+		return prefix
+	}
+
+	i := strings.Index(chapterPrefixes, prefix)
+	if i < 0 {
+		return ""
+	}
+
+	return twoDigits(i + 1)
+}
+
+// moduleIPrefixes lists the second character of the codes of Module I,
+// SA00 to SJ3Z, which precede the TM2 codes of chapter 26 from SK00 on.
+const moduleIPrefixes = "ABCDEFGHJ"
+
+// inModuleI reports whether code, or the code range of a block id such as
+// BlockL1-SA0, is one of Module I.
+func inModuleI(code string) bool {
+	if _, blockCode, ok := strings.Cut(code, "-"); ok {
+		code = blockCode
+	}
+	if len(code) < 2 || ICDChapter(code) != "26" {
+		return false
+	}
+
+	return strings.ContainsRune(moduleIPrefixes, rune(strings.ToUpper(code)[1]))
+}
+
+// IsTM2 reports whether code is a TM2 code of ICD-11.
+func IsTM2(code string) bool {
+	return inChapters(code, TM2Chapters)
+}
+
+func twoDigits(n int) string {
+	return string([]byte{byte('0' + n/10), byte('0' + n%10)})
+}
+
+// inChapters reports whether code belongs to one of chapters. Every code
+// belongs to an empty set of chapters, and the codes of Module I to no other.
+func inChapters(code string, chapters []string) bool {
+	if len(chapters) == 0 {
+		return true
+	}
+	if inModuleI(code) {
+		return false
+	}
+
+	chapter := ICDChapter(code)
+	for _, c := range chapters {
+		if c == chapter {
+			return true
+		}
+	}
+
+	return false
+}
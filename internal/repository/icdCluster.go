@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidCluster is returned for a postcoordinated code that is not
+// written the way ICD-11 clusters are.
+var ErrInvalidCluster = errors.New("invalid ICD-11 cluster")
+
+// The second character of an ICD-11 code is a letter and the third a digit,
+// which tells codes from words such as "cold".
+var (
+	stemCodePattern      = regexp.MustCompile(`^[0-9A-WYZ][A-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,2})?$`)
+	extensionCodePattern = regexp.MustCompile(`^X[A-Z][0-9A-Z]{2,4}$`)
+)
+
+// ICDCluster is a postcoordinated ICD-11 code. Stem codes are joined by "/",
+// such as a Module I disorder and its pattern in "SA80/SF57", and the
+// extension codes that qualify a stem follow it joined by "&", such as a
+// severity in "1A00&XS25".
+type ICDCluster struct {
+	Stems []ICDClusterStem
+}
+
+// ICDClusterStem is a stem code of a cluster with its extension codes.
+type ICDClusterStem struct {
+	Code       string
+	Extensions []string
+}
+
+// IsICDCluster reports whether code is written as a cluster rather than as a
+// single code, that is whether it joins codes with "/" or "&". Text that
+// merely contains those characters, such as "cough & cold", is not. The
+// codes may still be joined the wrong way.
+func IsICDCluster(code string) bool {
+	if !strings.ContainsAny(code, "/&") {
+		return false
+	}
+
+	for _, part := range strings.FieldsFunc(code, func(r rune) bool { return r == '/' || r == '&' }) {
+		part = strings.ToUpper(strings.TrimSpace(part))
+		if !stemCodePattern.MatchString(part) && !extensionCodePattern.MatchString(part) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseICDCluster parses a cluster expression. Codes are upper-cased, and a
+// single stem code is a cluster of one.
+func ParseICDCluster(expression string) (ICDCluster, error) {
+	var cluster ICDCluster
+
+	for _, group := range strings.Split(strings.TrimSpace(expression), "/") {
+		codes := strings.Split(group, "&")
+
+		stem := ICDClusterStem{Code: strings.ToUpper(strings.TrimSpace(codes[0]))}
+		if !stemCodePattern.MatchString(stem.Code) {
+			if extensionCodePattern.MatchString(stem.Code) {
+				return ICDCluster{}, fmt.Errorf("%w: extension code %s must follow a stem code with &", ErrInvalidCluster, stem.Code)
+			}
+			return ICDCluster{}, fmt.Errorf("%w: %q is not a stem code", ErrInvalidCluster, codes[0])
+		}
+
+		for _, code := range codes[1:] {
+			extension := strings.ToUpper(strings.TrimSpace(code))
+			if !extensionCodePattern.MatchString(extension) {
+				return ICDCluster{}, fmt.Errorf("%w: %q is not an extension code", ErrInvalidCluster, code)
+			}
+			stem.Extensions = append(stem.Extensions, extension)
+		}
+
+		cluster.Stems = append(cluster.Stems, stem)
+	}
+
+	return cluster, nil
+}
+
+// String returns the cluster written the canonical way, without spaces.
+func (c ICDCluster) String() string {
+	stems := make([]string, 0, len(c.Stems))
+	for _, stem := range c.Stems {
+		stems = append(stems, strings.Join(append([]string{stem.Code}, stem.Extensions...), "&"))
+	}
+
+	return strings.Join(stems, "/")
+}
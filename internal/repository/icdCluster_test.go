@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"backend/internal/repository"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseICDCluster(t *testing.T) {
+	tests := []struct {
+		expression string
+		canonical  string
+		stems      []string
+	}{
+		{"1A00", "1A00", []string{"1A00"}},
+		{"1a00&xs25", "1A00&XS25", []string{"1A00"}},
+		{"SA80/SF57", "SA80/SF57", []string{"SA80", "SF57"}},
+		{" NE84 & XA5UN0 & XK8G / PB10 ", "NE84&XA5UN0&XK8G/PB10", []string{"NE84", "PB10"}},
+		{"2C10.0&XH9XH0", "2C10.0&XH9XH0", []string{"2C10.0"}},
+	}
+
+	for _, test := range tests {
+		cluster, err := repository.ParseICDCluster(test.expression)
+		if err != nil {
+			t.Errorf("ParseICDCluster(%q): %v", test.expression, err)
+			continue
+		}
+		if got := cluster.String(); got != test.canonical {
+			t.Errorf("ParseICDCluster(%q) = %s, want %s", test.expression, got, test.canonical)
+		}
+
+		var stems []string
+		for _, stem := range cluster.Stems {
+			stems = append(stems, stem.Code)
+		}
+		if !slices.Equal(stems, test.stems) {
+			t.Errorf("ParseICDCluster(%q) stems = %v, want %v", test.expression, stems, test.stems)
+		}
+	}
+}
+
+func TestParseICDClusterInvalid(t *testing.T) {
+	for _, expression := range []string{"", "1A00/", "1A00&", "XS25", "XS25&1A00", "1A00&1A01", "1A00/XS25", "cholera"} {
+		if _, err := repository.ParseICDCluster(expression); !errors.Is(err, repository.ErrInvalidCluster) {
+			t.Errorf("ParseICDCluster(%q) = %v, want ErrInvalidCluster", expression, err)
+		}
+	}
+}
+
+func TestIsICDCluster(t *testing.T) {
+	for code, want := range map[string]bool{
+		"1A00":         false,
+		"SA80/SF57":    true,
+		"1A00&XS25":    true,
+		"sa80 / sf57":  true,
+		"XS25&1A00":    true,
+		"cough & cold": false,
+		"vata/pitta":   false,
+		"cold/cold":    false,
+		"1A00/":        true,
+	} {
+		if got := repository.IsICDCluster(code); got != want {
+			t.Errorf("IsICDCluster(%q) = %t, want %t", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// ErrInvalidEdition is returned for an ICD-11 edition that is malformed or
+// that the repository cannot serve.
+var ErrInvalidEdition = errors.New("invalid ICD-11 edition")
+
+// ICDEdition selects what an ICDRepository serves: a release of ICD-11, one
+// of its linearizations, and the language of its titles and definitions.
+type ICDEdition struct {
+	// Release is the id of a WHO release, such as "2025-01".
+	Release string
+	// Linearization is the name of a linearization of the release, such as
+	// "mms".
+	Linearization string
+	// Language is the language titles and definitions are asked for in, such
+	// as "en" or "hi". It is only honoured where WHO has translated the
+	// release.
+	Language string
+}
+
+// DefaultICDEdition is used for the fields of an edition that are not set.
+var DefaultICDEdition = ICDEdition{
+	Release:       "2025-01",
+	Linearization: "mms",
+	Language:      "en",
+}
+
+var (
+	releasePattern       = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	linearizationPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	languagePattern      = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+)
+
+// Or returns the edition with its empty fields taken from fallback.
+func (e ICDEdition) Or(fallback ICDEdition) ICDEdition {
+	if e.Release == "" {
+		e.Release = fallback.Release
+	}
+	if e.Linearization == "" {
+		e.Linearization = fallback.Linearization
+	}
+	if e.Language == "" {
+		e.Language = fallback.Language
+	}
+
+	return e
+}
+
+// Validate checks that the fields of the edition are well formed, as they
+// end up in the URLs of the WHO API.
+func (e ICDEdition) Validate() error {
+	if !releasePattern.MatchString(e.Release) {
+		return fmt.Errorf("%w: release %q is not like 2025-01", ErrInvalidEdition, e.Release)
+	}
+	if !linearizationPattern.MatchString(e.Linearization) {
+		return fmt.Errorf("%w: linearization %q is not like mms", ErrInvalidEdition, e.Linearization)
+	}
+	if !languagePattern.MatchString(e.Language) {
+		return fmt.Errorf("%w: language %q is not a language code", ErrInvalidEdition, e.Language)
+	}
+
+	return nil
+}
+
+func (e ICDEdition) String() string {
+	return e.Release + "/" + e.Linearization + "/" + e.Language
+}
+
+// icdLanguages remembers the languages WHO has translated each release of a
+// linearization into, which are shared by every view of a repository.
+type icdLanguages struct {
+	mu        sync.Mutex
+	languages map[string][]string
+}
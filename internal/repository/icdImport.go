@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+)
+
+// ICDEntity is an entity of an ICD-11 linearization as stored in the local
+// index. Chapters and blocks have no code, so entities are keyed by Key,
+// which is the code, the block id, or the chapter number.
+type ICDEntity struct {
+	Key        string
+	Code       string
+	Title      string
+	Definition string
+	ClassKind  string
+	Chapter    string
+	Parent     string
+	Coded      bool
+	Order      float64
+}
+
+// ImportICDRelease indexes a WHO ICD-11 linearization tabulation, such as
+// LinearizationMiniOutput-MMS-en.txt, into a new bleve index at indexPath.
+// Definitions are taken from the export at definitionsPath unless it is
+// empty, and edition is stored with the index.
+func ImportICDRelease(tabulationPath string, definitionsPath string, indexPath string, edition ICDEdition) error {
+	edition = edition.Or(DefaultICDEdition)
+	if err := edition.Validate(); err != nil {
+		return err
+	}
+
+	definitions := make(map[string]string)
+	if definitionsPath != "" {
+		var err error
+		definitions, err = readICDDefinitions(definitionsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(tabulationPath)
+	if err != nil {
+		return fmt.Errorf("error opening tabulation: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading tabulation header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+
+	for _, required := range []string{"Code", "BlockId", "Title", "ClassKind", "ChapterNo"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("tabulation has no %s column", required)
+		}
+	}
+
+	column := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	os.RemoveAll(indexPath)
+
+	index, err := bleve.New(indexPath, bleve.NewIndexMapping())
+	if err != nil {
+		return fmt.Errorf("error creating bleve index: %w", err)
+	}
+	defer index.Close()
+
+	log.Println("Starting to index ICD-11 entities...")
+
+	// ancestors holds the key of the last entity seen at each depth, so the
+	// parent of an entity is the last one seen one level up.
+	var ancestors []string
+	batch := index.NewBatch()
+	count := 0
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tabulation: %w", err)
+		}
+
+		title, depth := tabulationTitle(column(row, "Title"))
+
+		entity := ICDEntity{
+			Code:       column(row, "Code"),
+			Title:      title,
+			Definition: column(row, "Definition"),
+			ClassKind:  column(row, "ClassKind"),
+			Chapter:    column(row, "ChapterNo"),
+			Order:      float64(count),
+		}
+
+		switch {
+		case entity.Code != "":
+			entity.Key = entity.Code
+			entity.Coded = true
+		case column(row, "BlockId") != "":
+			entity.Key = column(row, "BlockId")
+		default:
+			entity.Key = entity.Chapter
+		}
+
+		if entity.Key == "" {
+			continue
+		}
+		if entity.Definition == "" {
+			entity.Definition = definitions[entity.Key]
+		}
+
+		ancestors = append(ancestors[:min(depth, len(ancestors))], entity.Key)
+		if p := len(ancestors) - 1; p > 0 {
+			entity.Parent = ancestors[p-1]
+		}
+
+		if err := batch.Index(entity.Key, entity); err != nil {
+			return fmt.Errorf("unable to index entity %s: %w", entity.Key, err)
+		}
+		count++
+
+		if batch.Size() >= 1000 {
+			if err := index.Batch(batch); err != nil {
+				return fmt.Errorf("unable to index batch: %w", err)
+			}
+			batch = index.NewBatch()
+		}
+	}
+
+	if err := index.Batch(batch); err != nil {
+		return fmt.Errorf("unable to index batch: %w", err)
+	}
+
+	value, err := json.Marshal(edition)
+	if err != nil {
+		return err
+	}
+	if err := index.SetInternal(icdEditionKey, value); err != nil {
+		return fmt.Errorf("unable to store edition: %w", err)
+	}
+
+	log.Printf("Successfully indexed %d ICD-11 entities", count)
+	return nil
+}
+
+// ExportICDDefinitions writes the definitions of icdRepository to
+// definitionsPath as tab separated Code and Definition columns, for
+// ImportICDRelease. It returns the number of definitions written.
+func ExportICDDefinitions(ctx context.Context, icdRepository ICDRepository, definitionsPath string) (int, error) {
+	entities, err := icdRepository.List(ctx, math.MaxInt)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(definitionsPath)
+	if err != nil {
+		return 0, fmt.Errorf("error creating definitions: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = '\t'
+
+	count := 0
+	writer.Write([]string{"Code", "Definition"})
+	for _, entity := range entities {
+		if entity.Desc == "" {
+			continue
+		}
+
+		// Definitions are kept on one line, as the tabulations are.
+		writer.Write([]string{entity.ID, strings.Join(strings.Fields(entity.Desc), " ")})
+		count++
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, fmt.Errorf("error writing definitions: %w", err)
+	}
+
+	return count, file.Close()
+}
+
+// readICDDefinitions reads the definitions written by ExportICDDefinitions,
+// by the key of their entity.
+func readICDDefinitions(definitionsPath string) (map[string]string, error) {
+	file, err := os.Open(definitionsPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening definitions: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = '\t'
+	reader.FieldsPerRecord = 2
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading definitions header: %w", err)
+	}
+	if header[0] != "Code" || header[1] != "Definition" {
+		return nil, fmt.Errorf("definitions must have a Code and a Definition column, found %v", header)
+	}
+
+	definitions := make(map[string]string)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading definitions: %w", err)
+		}
+
+		definitions[row[0]] = row[1]
+	}
+
+	return definitions, nil
+}
+
+// tabulationTitle strips the "- " markers that indent titles in the
+// tabulation and returns the depth they encode.
+func tabulationTitle(title string) (string, int) {
+	depth := 0
+	for {
+		rest, ok := strings.CutPrefix(title, "-")
+		if !ok {
+			break
+		}
+		title = strings.TrimLeft(rest, " ")
+		depth++
+	}
+
+	return title, depth
+}
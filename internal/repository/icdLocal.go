@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// icdEditionKey is the internal key of a local index under which the edition
+// it was imported from is stored.
+var icdEditionKey = []byte("edition")
+
+// localICDRepository serves ICD-11 from an index built by ImportICDRelease,
+// for deployments that cannot reach the WHO API.
+type localICDRepository struct {
+	index   bleve.Index
+	edition ICDEdition
+
+	// chapters restricts the repository to these chapters. Empty means the
+	// whole linearization.
+	chapters []string
+}
+
+func NewLocalICDRepository(indexPath string) (ICDRepository, error) {
+	index, err := bleve.Open(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ICD index: %w", err)
+	}
+
+	// Indexes imported before editions were stored hold the default one.
+	edition := DefaultICDEdition
+	value, err := index.GetInternal(icdEditionKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ICD index edition: %w", err)
+	}
+	if value != nil {
+		if err := json.Unmarshal(value, &edition); err != nil {
+			return nil, fmt.Errorf("unable to read ICD index edition: %w", err)
+		}
+	}
+
+	return &localICDRepository{index: index, edition: edition}, nil
+}
+
+// Chapters implements ICDRepository.
+func (l *localICDRepository) Chapters(chapters []string) ICDRepository {
+	return &localICDRepository{index: l.index, edition: l.edition, chapters: chapters}
+}
+
+// Edition implements ICDRepository.
+func (l *localICDRepository) Edition() ICDEdition {
+	return l.edition
+}
+
+// WithEdition implements ICDRepository. The index holds a single release in
+// a single language, so another release is an error and another language
+// falls back to the language of the index.
+func (l *localICDRepository) WithEdition(ctx context.Context, edition ICDEdition) (ICDRepository, error) {
+	edition = edition.Or(l.edition)
+	if err := edition.Validate(); err != nil {
+		return nil, err
+	}
+
+	if edition.Release != l.edition.Release || edition.Linearization != l.edition.Linearization {
+		return nil, fmt.Errorf("%w: only release %s of %s is available", ErrInvalidEdition, l.edition.Release, l.edition.Linearization)
+	}
+
+	return l, nil
+}
+
+// Find implements ICDRepository.
+func (l *localICDRepository) Find(ctx context.Context, input string) (*ICDMatches, error) {
+	matches, _, err := l.Search(ctx, input, 0, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ICDMatches{
+		Matches: matches,
+	}, nil
+}
+
+// List implements ICDRepository. Entities are listed in the order of the
+// tabulation they were imported from, chapters and blocks included, so the
+// list carries the whole hierarchy.
+func (l *localICDRepository) List(ctx context.Context, size int) ([]ICDMatch, error) {
+	searchRequest := bleve.NewSearchRequestOptions(l.chapterQuery(query.NewMatchAllQuery()), max(size, 0), 0, false)
+	searchRequest.Fields = []string{"*"}
+	searchRequest.SortBy([]string{"Order"})
+
+	searchResult, err := l.index.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search: %w", err)
+	}
+
+	matches := make([]ICDMatch, 0, len(searchResult.Hits))
+	indexes := make(map[string]int)
+	for _, hit := range searchResult.Hits {
+		match := matchFromHit(hit)
+		if parent, ok := indexes[match.Parent]; ok {
+			matches[parent].Children = append(matches[parent].Children, match.ID)
+		}
+
+		indexes[match.ID] = len(matches)
+		matches = append(matches, match)
+	}
+
+	return matches, nil
+}
+
+// Get implements ICDRepository.
+func (l *localICDRepository) Get(ctx context.Context, code string) (*ICDMatch, error) {
+	// Codes are indexed as WHO writes them, in upper case.
+	code = strings.ToUpper(code)
+	if !inChapters(code, l.chapters) {
+		return nil, ErrNotFound
+	}
+
+	searchRequest := bleve.NewSearchRequest(query.NewDocIDQuery([]string{code}))
+	searchRequest.Fields = []string{"*"}
+
+	searchResult, err := l.index.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search: %w", err)
+	}
+
+	if len(searchResult.Hits) == 0 {
+		return nil, ErrNotFound
+	}
+
+	// Chapters and blocks are indexed for the hierarchy but are not codes.
+	if coded, _ := searchResult.Hits[0].Fields["Coded"].(bool); !coded {
+		return nil, ErrNotFound
+	}
+
+	match := matchFromHit(searchResult.Hits[0])
+	return &match, nil
+}
+
+// Search implements ICDRepository.
+func (l *localICDRepository) Search(ctx context.Context, input string, offset int, size int) ([]ICDMatch, int, error) {
+	searchRequest := bleve.NewSearchRequestOptions(l.chapterQuery(codedQuery(query.NewMatchQuery(input))), size, offset, false)
+	searchRequest.Fields = []string{"*"}
+
+	searchResult, err := l.index.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to search: %w", err)
+	}
+
+	matches := make([]ICDMatch, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		matches = append(matches, matchFromHit(hit))
+	}
+
+	return matches, int(searchResult.Total), nil
+}
+
+// codedQuery restricts q to entities that have a code, leaving out chapters
+// and blocks.
+func codedQuery(q query.Query) query.Query {
+	hasCode := query.NewBoolFieldQuery(true)
+	hasCode.SetField("Coded")
+
+	return query.NewConjunctionQuery([]query.Query{q, hasCode})
+}
+
+// chapterQuery restricts q to the chapters of the repository.
+func (l *localICDRepository) chapterQuery(q query.Query) query.Query {
+	if len(l.chapters) == 0 {
+		return q
+	}
+
+	chapters := make([]query.Query, 0, len(l.chapters))
+	for _, chapter := range l.chapters {
+		term := query.NewTermQuery(chapter)
+		term.SetField("Chapter")
+		chapters = append(chapters, term)
+	}
+
+	// Module I is left out by its keys, which are indexed in lower case,
+	// codes whole and block ids split at the dash.
+	moduleI := query.NewRegexpQuery("s[" + strings.ToLower(moduleIPrefixes) + "].*")
+	moduleI.SetField("Key")
+
+	return query.NewBooleanQuery([]query.Query{q, query.NewDisjunctionQuery(chapters)}, nil, []query.Query{moduleI})
+}
+
+func matchFromHit(hit *search.DocumentMatch) ICDMatch {
+	field := func(name string) string {
+		value, _ := hit.Fields[name].(string)
+		return value
+	}
+
+	return ICDMatch{
+		ID:     field("Key"),
+		Name:   field("Title"),
+		Desc:   field("Definition"),
+		Kind:   field("ClassKind"),
+		Parent: field("Parent"),
+	}
+}
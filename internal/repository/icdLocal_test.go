@@ -0,0 +1,235 @@
+package repository_test
+
+import (
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const tabulationPath = "testdata/LinearizationMiniOutput-MMS-en.txt"
+
+func newLocalRepository(t *testing.T, definitionsPath string, edition repository.ICDEdition) repository.ICDRepository {
+	t.Helper()
+
+	indexPath := filepath.Join(t.TempDir(), "icd.bleve")
+	if err := repository.ImportICDRelease(tabulationPath, definitionsPath, indexPath, edition); err != nil {
+		t.Fatalf("ImportICDRelease: %v", err)
+	}
+
+	icdRepository, err := repository.NewLocalICDRepository(indexPath)
+	if err != nil {
+		t.Fatalf("NewLocalICDRepository: %v", err)
+	}
+	return icdRepository
+}
+
+func TestLocalICDList(t *testing.T) {
+	icdRepository := newLocalRepository(t, "", repository.ICDEdition{})
+
+	list, err := icdRepository.List(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	want := []repository.ICDMatch{
+		{ID: "01", Name: "Certain infectious or parasitic diseases", Kind: "chapter", Children: []string{"BlockL1-1A0"}},
+		{ID: "BlockL1-1A0", Name: "Gastroenteritis or colitis of infectious origin", Kind: "block", Parent: "01", Children: []string{"1A00", "1A01"}},
+		{ID: "1A00", Name: "Cholera", Kind: "category", Parent: "BlockL1-1A0"},
+		{ID: "1A01", Name: "Intestinal infection due to other Vibrio", Kind: "category", Parent: "BlockL1-1A0"},
+		{ID: "26", Name: "Supplementary Chapter Traditional Medicine Conditions", Kind: "chapter", Children: []string{"SA80", "SA81", "SK60"}},
+		{ID: "SA80", Name: "Fever disorder (TM1)", Kind: "category", Parent: "26"},
+		{ID: "SA81", Name: "Liver system disorders (TM1)", Kind: "category", Parent: "26"},
+		{ID: "SK60", Name: "Fever disorder (TM2)", Kind: "category", Parent: "26"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("List returned %d entities, want %d", len(list), len(want))
+	}
+	for i, match := range list {
+		if match.ID != want[i].ID || match.Name != want[i].Name || match.Kind != want[i].Kind || match.Parent != want[i].Parent || !slices.Equal(match.Children, want[i].Children) {
+			t.Errorf("entity %d = %+v, want %+v", i, match, want[i])
+		}
+	}
+
+	for _, size := range []int{-1, 0} {
+		if list, err := icdRepository.List(context.Background(), size); err != nil || len(list) != 0 {
+			t.Errorf("List(%d) = %v, %v, want nothing", size, list, err)
+		}
+	}
+
+	tm2, err := icdRepository.Chapters(repository.TM2Chapters).List(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	// SA80 and SA81 are in Module I.
+	if len(tm2) != 2 || tm2[0].ID != "26" || tm2[1].ID != "SK60" {
+		t.Errorf("List of TM2 returned %v, want 26 and SK60", tm2)
+	}
+}
+
+func TestLocalICDGet(t *testing.T) {
+	icdRepository := newLocalRepository(t, "", repository.ICDEdition{})
+
+	for _, code := range []string{"1A00", "1a00"} {
+		match, err := icdRepository.Get(context.Background(), code)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", code, err)
+		}
+		if match.ID != "1A00" || match.Name != "Cholera" || match.Parent != "BlockL1-1A0" {
+			t.Errorf("Get(%s) returned %+v", code, match)
+		}
+	}
+	if match, err := icdRepository.Chapters(repository.TM2Chapters).Get(context.Background(), "sk60"); err != nil || match.ID != "SK60" {
+		t.Errorf("Get(sk60) in TM2 returned %v, %v, want SK60", match, err)
+	}
+
+	// Chapters and blocks are not codes, and codes outside the chapters of
+	// the repository are not found.
+	for _, code := range []string{"01", "BlockL1-1A0", "1A99"} {
+		if _, err := icdRepository.Get(context.Background(), code); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Get(%s) returned %v, want ErrNotFound", code, err)
+		}
+	}
+	for _, code := range []string{"1A00", "SA80"} {
+		if _, err := icdRepository.Chapters(repository.TM2Chapters).Get(context.Background(), code); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Get(%s) outside TM2 returned %v, want ErrNotFound", code, err)
+		}
+	}
+}
+
+func TestLocalICDSearch(t *testing.T) {
+	icdRepository := newLocalRepository(t, "", repository.ICDEdition{})
+
+	matches, total, err := icdRepository.Search(context.Background(), "cholera", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if total != 1 || len(matches) != 1 || matches[0].ID != "1A00" {
+		t.Errorf("Search returned %v of %d, want only 1A00", matches, total)
+	}
+
+	// Chapters and blocks are never found, only codes.
+	matches, _, err = icdRepository.Search(context.Background(), "infectious", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("Search found %v, want no chapter or block", matches)
+	}
+
+	matches, total, err = icdRepository.Search(context.Background(), "tm1", 0, 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if total != 2 || len(matches) != 1 || repository.ICDChapter(matches[0].ID) != "26" {
+		t.Errorf("Search returned %v of %d, want one of 2 Module I codes", matches, total)
+	}
+
+	// Module I is in chapter 26 but not in TM2.
+	matches, total, err = icdRepository.Chapters(repository.TM2Chapters).Search(context.Background(), "fever", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if total != 1 || len(matches) != 1 || matches[0].ID != "SK60" {
+		t.Errorf("Search of TM2 returned %v of %d, want only SK60", matches, total)
+	}
+}
+
+func TestLocalICDEdition(t *testing.T) {
+	edition := repository.ICDEdition{Release: "2024-01", Linearization: "mms", Language: "es"}
+	icdRepository := newLocalRepository(t, "", edition)
+
+	if got := icdRepository.Edition(); got != edition {
+		t.Errorf("Edition() = %v, want %v", got, edition)
+	}
+
+	if _, err := icdRepository.WithEdition(context.Background(), repository.ICDEdition{Release: "2025-01"}); !errors.Is(err, repository.ErrInvalidEdition) {
+		t.Errorf("WithEdition of another release returned %v, want ErrInvalidEdition", err)
+	}
+
+	// The index has a single language, which other languages fall back to.
+	view, err := icdRepository.WithEdition(context.Background(), repository.ICDEdition{Language: "hi"})
+	if err != nil {
+		t.Fatalf("WithEdition: %v", err)
+	}
+	if got := view.Edition(); got != edition {
+		t.Errorf("WithEdition in hi serves %v, want %v", got, edition)
+	}
+}
+
+func TestImportICDReleaseWithDefinitions(t *testing.T) {
+	server := newServer(t)
+	remote := newRepository(t, server, testOptions, nil)
+
+	definitionsPath := filepath.Join(t.TempDir(), "definitions.tsv")
+	count, err := repository.ExportICDDefinitions(context.Background(), remote, definitionsPath)
+	if err != nil {
+		t.Fatalf("ExportICDDefinitions: %v", err)
+	}
+	// Chapter 01, 1A00, SA80 and SK60 have definitions.
+	if count != 4 {
+		t.Errorf("ExportICDDefinitions wrote %d definitions, want 4", count)
+	}
+
+	without := newLocalRepository(t, "", repository.ICDEdition{})
+	local := newLocalRepository(t, definitionsPath, repository.ICDEdition{})
+
+	for _, code := range []string{"1A00", "SA80", "SK60", "1A01"} {
+		want, err := remote.Get(context.Background(), code)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+
+		match, err := local.Get(context.Background(), code)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if match.Desc != want.Desc {
+			t.Errorf("definition of %s = %q, want %q", code, match.Desc, want.Desc)
+		}
+
+		match, err = without.Get(context.Background(), code)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if match.Desc != "" {
+			t.Errorf("definition of %s imported without definitions = %q, want none", code, match.Desc)
+		}
+	}
+
+	// Definitions are searched like titles.
+	matches, _, err := local.Search(context.Background(), "diarrhoeal", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != "1A00" {
+		t.Errorf("Search of a definition returned %v, want 1A00", matches)
+	}
+}
+
+func TestImportICDReleaseInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	tabulation := filepath.Join(dir, "tabulation.txt")
+	if err := os.WriteFile(tabulation, []byte("Code\tTitle\tClassKind\n1A00\tCholera\tcategory\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.ImportICDRelease(tabulation, "", filepath.Join(dir, "icd.bleve"), repository.ICDEdition{}); err == nil {
+		t.Error("ImportICDRelease of a tabulation without BlockId and ChapterNo succeeded")
+	}
+
+	definitions := filepath.Join(dir, "definitions.tsv")
+	if err := os.WriteFile(definitions, []byte("Key\tText\n1A00\tCholera\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.ImportICDRelease(tabulationPath, definitions, filepath.Join(dir, "icd.bleve"), repository.ICDEdition{}); err == nil {
+		t.Error("ImportICDRelease with definitions without a Code and a Definition column succeeded")
+	}
+
+	if err := repository.ImportICDRelease(tabulationPath, "", filepath.Join(dir, "icd.bleve"), repository.ICDEdition{Release: "latest"}); !errors.Is(err, repository.ErrInvalidEdition) {
+		t.Errorf("ImportICDRelease of an invalid edition returned %v, want ErrInvalidEdition", err)
+	}
+}
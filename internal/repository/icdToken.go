@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"backend/cmd/web/dto"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenSource supplies the access tokens the WHO API is called with.
+type tokenSource interface {
+	// Token returns a token that has not expired, fetching a new one if
+	// needed.
+	Token(ctx context.Context) (string, error)
+	// Invalidate discards token after WHO rejected it, so that the next call
+	// of Token fetches another. A token that has already been replaced is
+	// left alone.
+	Invalidate(token string)
+}
+
+const (
+	// tokenExpiryMargin is how long before the expiry WHO announces a token
+	// stops being used, to allow for clock skew and slow requests.
+	tokenExpiryMargin = time.Minute
+	// tokenRefreshLead is how long before a token stops being used a new
+	// one is fetched in the background, so callers never wait for it.
+	tokenRefreshLead = 5 * time.Minute
+)
+
+// whoTokenSource fetches tokens from the WHO access management server with
+// the client credentials grant. Callers that need a token while one is being
+// fetched wait for it rather than fetching their own, so a burst of requests
+// or of rejections costs a single fetch.
+type whoTokenSource struct {
+	client       *whoClient
+	tokenURL     string
+	clientID     string
+	clientSecret string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	// used tells whether the token has been handed out, as only tokens in
+	// use are refreshed in the background.
+	used bool
+	// refreshing is closed once the fetch in flight is over, with its
+	// failure in err. It is nil when no fetch is in flight.
+	refreshing chan struct{}
+	err        error
+}
+
+func newWHOTokenSource(client *whoClient, endpoint WHOEndpoint) *whoTokenSource {
+	return &whoTokenSource{
+		client:       client,
+		tokenURL:     endpoint.TokenURL,
+		clientID:     endpoint.ClientID,
+		clientSecret: endpoint.ClientSecret,
+	}
+}
+
+// Token implements tokenSource. A caller that gives up waiting does not stop
+// the fetch, whose token the next caller gets.
+func (s *whoTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.token != "" && time.Now().Before(s.expiry) {
+		s.used = true
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+	refreshing := s.refresh()
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-refreshing:
+		case <-ctx.Done():
+			return "", whoError(ctx.Err())
+		}
+
+		s.mu.Lock()
+		if s.err != nil {
+			err := s.err
+			s.mu.Unlock()
+			return "", err
+		}
+
+		// The token just fetched is handed out even if it is too short-lived
+		// to be kept, unless it has been rejected meanwhile.
+		if s.token != "" {
+			s.used = true
+			token := s.token
+			s.mu.Unlock()
+			return token, nil
+		}
+		refreshing = s.refresh()
+		s.mu.Unlock()
+	}
+}
+
+// Invalidate implements tokenSource.
+func (s *whoTokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		whoMetrics.Add("token_rejections", 1)
+		s.token = ""
+	}
+}
+
+// refresh starts fetching a token unless a fetch is already in flight, and
+// returns the channel closed when it is over. s.mu must be held.
+func (s *whoTokenSource) refresh() chan struct{} {
+	if s.refreshing != nil {
+		return s.refreshing
+	}
+
+	refreshing := make(chan struct{})
+	s.refreshing = refreshing
+
+	go func() {
+		token, lifetime, err := s.fetch(context.Background())
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.refreshing = nil
+		s.err = err
+		if err == nil {
+			whoMetrics.Add("token_refreshes", 1)
+			s.token = token
+			s.expiry = time.Now().Add(lifetime)
+			s.used = false
+			s.schedule(token, lifetime)
+		}
+		close(refreshing)
+	}()
+
+	return refreshing
+}
+
+// schedule refreshes token in the background ahead of its expiry, if it is
+// still current and in use by then. A token too short-lived to be refreshed
+// ahead is refreshed when it is next needed. s.mu must be held.
+func (s *whoTokenSource) schedule(token string, lifetime time.Duration) {
+	lead := min(tokenRefreshLead, lifetime/5)
+	if lead <= 0 {
+		return
+	}
+
+	time.AfterFunc(lifetime-lead, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.token != token || !s.used {
+			return
+		}
+
+		refreshing := s.refresh()
+		go func() {
+			<-refreshing
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.err != nil {
+				log.Println("Error: unable to refresh WHO API token ahead of its expiry: " + s.err.Error())
+			}
+		}()
+	})
+}
+
+// fetch requests a token with the client credentials, and returns it with
+// how long it can be used.
+func (s *whoTokenSource) fetch(ctx context.Context) (string, time.Duration, error) {
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", s.clientID)
+	data.Set("client_secret", s.clientSecret)
+	data.Set("scope", "icdapi_access")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, whoError(fmt.Errorf("bad token response: %s", resp.Status))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, whoError(fmt.Errorf("read body failed: %w", err))
+	}
+
+	var tokenResp dto.TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, whoError(fmt.Errorf("decode token failed: %w", err))
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, whoError(errors.New("token response has no access token"))
+	}
+
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn)*time.Second - tokenExpiryMargin, nil
+}
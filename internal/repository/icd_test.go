@@ -0,0 +1,620 @@
+package repository_test
+
+import (
+	"backend/internal/repository"
+	"backend/internal/repository/icdapitest"
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	releasePath  = "/icd/release/11/2025-01/mms"
+	searchPath   = releasePath + "/search"
+	choleraPath  = releasePath + "/257068234"
+	feverTMPath  = releasePath + "/1974218130"
+	feverPath    = releasePath + "/1362046863"
+	codeInfoPath = releasePath + "/codeinfo/"
+)
+
+// testOptions keeps retries fast, and the breaker out of the way of the
+// tests that do not look at it.
+var testOptions = repository.WHOClientOptions{
+	Timeout:          5 * time.Second,
+	Retries:          2,
+	MinBackoff:       time.Millisecond,
+	MaxBackoff:       5 * time.Millisecond,
+	BreakerThreshold: 100,
+}
+
+func newServer(t *testing.T) *icdapitest.Server {
+	t.Helper()
+
+	server := icdapitest.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRepository(t *testing.T, server *icdapitest.Server, options repository.WHOClientOptions, cache repository.ICDCache) repository.ICDRepository {
+	t.Helper()
+
+	icdRepository, err := repository.NewICDRepository(server.Client(), server.Endpoint(), options, cache, repository.ICDEdition{})
+	if err != nil {
+		t.Fatalf("NewICDRepository: %v", err)
+	}
+	return icdRepository
+}
+
+func TestICDFind(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	matches, err := icdRepository.Find(context.Background(), "cholera")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	ids := make([]string, 0, len(matches.Matches))
+	for _, match := range matches.Matches {
+		ids = append(ids, match.ID)
+	}
+	if !slices.Equal(ids, []string{"1A00", "1A01"}) {
+		t.Fatalf("Find returned %v, want [1A00 1A01]", ids)
+	}
+
+	cholera := matches.Matches[0]
+	if cholera.Name != "Cholera" {
+		t.Errorf("Name = %q, want Cholera", cholera.Name)
+	}
+	// The search results carry no definition, so it is fetched.
+	if cholera.Desc == "" || server.Requests(choleraPath) != 1 {
+		t.Errorf("definition of 1A00 not fetched: Desc = %q after %d requests", cholera.Desc, server.Requests(choleraPath))
+	}
+	if matches.Matches[1].Desc != "" {
+		t.Errorf("1A01 has no definition, got %q", matches.Matches[1].Desc)
+	}
+	if len(matches.Undefined) != 0 {
+		t.Errorf("Undefined = %v, want none", matches.Undefined)
+	}
+}
+
+func TestICDFindUndefined(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	server.Fail(choleraPath, http.StatusInternalServerError, -1)
+
+	// The matches are returned without the definition that failed.
+	matches, err := icdRepository.Find(context.Background(), "cholera")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches.Matches) != 2 || matches.Matches[0].Desc != "" {
+		t.Fatalf("Find returned %v, want 1A00 without a definition and 1A01", matches.Matches)
+	}
+	if !slices.Equal(matches.Undefined, []string{"1A00"}) {
+		t.Errorf("Undefined = %v, want [1A00]", matches.Undefined)
+	}
+
+	// An entity whose id cannot be parsed is matched by title alone.
+	matches, err = icdRepository.Find(context.Background(), "fatigue")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches.Matches) != 1 || matches.Matches[0].ID != "MG22" || !slices.Equal(matches.Undefined, []string{"MG22"}) {
+		t.Errorf("Find returned %v, undefined %v, want MG22 undefined", matches.Matches, matches.Undefined)
+	}
+}
+
+func TestICDFindUsesSearchedDefinitions(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	matches, err := icdRepository.Find(context.Background(), "fever")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	if len(matches.Matches) != 3 {
+		t.Fatalf("Find returned %d matches, want 3", len(matches.Matches))
+	}
+	for _, match := range matches.Matches {
+		if match.Desc == "" {
+			t.Errorf("%s has no definition", match.ID)
+		}
+	}
+	if n := server.Requests(feverTMPath) + server.Requests(feverPath); n != 0 {
+		t.Errorf("fetched %d entities whose definition was in the search results", n)
+	}
+}
+
+func TestICDFindNothing(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	matches, err := icdRepository.Find(context.Background(), "xyzzy")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches.Matches) != 0 {
+		t.Errorf("Find returned %v, want nothing", matches.Matches)
+	}
+}
+
+func TestICDSearchChapters(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	matches, total, err := icdRepository.Chapters(repository.TM2Chapters).Search(context.Background(), "fever", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if total != 1 || len(matches) != 1 || matches[0].ID != "SK60" {
+		t.Errorf("Search returned %v of %d, want only SK60", matches, total)
+	}
+}
+
+func TestICDTM2LeavesOutModuleI(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+	tm2 := icdRepository.Chapters(repository.TM2Chapters)
+
+	// SA80 is in chapter 26 with SK60, but in Module I.
+	matches, _, err := icdRepository.Search(context.Background(), "fever", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if !slices.ContainsFunc(matches, func(match repository.ICDMatch) bool { return match.ID == "SA80" }) {
+		t.Fatalf("Search of every chapter returned %v, want SA80 among them", matches)
+	}
+
+	found, err := tm2.Find(context.Background(), "fever")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(found.Matches) != 1 || found.Matches[0].ID != "SK60" {
+		t.Errorf("Find of TM2 returned %v, want only SK60", found.Matches)
+	}
+
+	if _, err := tm2.Get(context.Background(), "SA80"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get of SA80 in TM2 returned %v, want ErrNotFound", err)
+	}
+	if match, err := tm2.Get(context.Background(), "SK60"); err != nil || match.ID != "SK60" {
+		t.Errorf("Get of SK60 in TM2 = %v, %v, want SK60", match, err)
+	}
+	if repository.IsTM2("SA80") || !repository.IsTM2("SK60") || repository.IsTM2("MG26") {
+		t.Error("IsTM2 takes Module I or biomedicine for TM2")
+	}
+}
+
+func TestICDList(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	list, err := icdRepository.List(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	want := []repository.ICDMatch{
+		{ID: "01", Kind: "chapter", Children: []string{"BlockL1-1A0"}},
+		{ID: "BlockL1-1A0", Kind: "block", Parent: "01", Children: []string{"1A00", "1A01"}},
+		{ID: "1A00", Kind: "category", Parent: "BlockL1-1A0"},
+		{ID: "1A01", Kind: "category", Parent: "BlockL1-1A0"},
+		{ID: "26", Kind: "chapter", Children: []string{"SA80", "SA81", "SK60"}},
+		{ID: "SA80", Kind: "category", Parent: "26"},
+		{ID: "SA81", Kind: "category", Parent: "26"},
+		{ID: "SK60", Kind: "category", Parent: "26"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("List returned %d entities, want %d", len(list), len(want))
+	}
+	for i, match := range list {
+		if match.ID != want[i].ID || match.Kind != want[i].Kind || match.Parent != want[i].Parent || !slices.Equal(match.Children, want[i].Children) {
+			t.Errorf("entity %d = %+v, want %+v", i, match, want[i])
+		}
+		if match.Name == "" {
+			t.Errorf("entity %s has no name", match.ID)
+		}
+	}
+}
+
+func TestICDListSize(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	list, err := icdRepository.List(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	if len(list) != 3 || list[2].ID != "1A00" {
+		t.Errorf("List(3) returned %v, want 01, BlockL1-1A0 and 1A00", list)
+	}
+}
+
+func TestICDListFetchesOnlyWhatFits(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	for _, size := range []int{-1, 0} {
+		list, err := icdRepository.List(context.Background(), size)
+		if err != nil || len(list) != 0 {
+			t.Errorf("List(%d) = %v, %v, want nothing", size, list, err)
+		}
+	}
+	if n := server.Requests(releasePath); n != 0 {
+		t.Errorf("List of nothing fetched the root %d times", n)
+	}
+
+	list, err := icdRepository.List(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "01" {
+		t.Errorf("List(1) returned %v, want 01", list)
+	}
+	for _, entityPath := range []string{releasePath + "/718687701", releasePath + "/135352227"} {
+		if n := server.Requests(entityPath); n != 0 {
+			t.Errorf("List(1) fetched %s, which does not fit, %d times", entityPath, n)
+		}
+	}
+}
+
+func TestICDListChapters(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	list, err := icdRepository.Chapters(repository.TM2Chapters).List(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	ids := make([]string, 0, len(list))
+	for _, match := range list {
+		ids = append(ids, match.ID)
+	}
+	// SA80 and SA81 are in Module I.
+	if !slices.Equal(ids, []string{"26", "SK60"}) {
+		t.Errorf("List returned %v, want [26 SK60]", ids)
+	}
+}
+
+func TestICDGet(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	match, err := icdRepository.Get(context.Background(), "1A00")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if match.ID != "1A00" || match.Name != "Cholera" || match.Desc == "" {
+		t.Errorf("Get returned %+v", match)
+	}
+}
+
+func TestICDGetNotFound(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	if _, err := icdRepository.Get(context.Background(), "1A99"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get of an unknown code returned %v, want ErrNotFound", err)
+	}
+
+	// A code outside the chapters is not looked up at all.
+	if _, err := icdRepository.Chapters(repository.TM2Chapters).Get(context.Background(), "1A00"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get outside the chapters returned %v, want ErrNotFound", err)
+	}
+	if n := server.Requests(codeInfoPath + "1A00"); n != 0 {
+		t.Errorf("looked up a code outside the chapters %d times", n)
+	}
+}
+
+func TestICDTokenReused(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	for range 3 {
+		if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+	}
+
+	if n := server.TokensIssued(); n != 1 {
+		t.Errorf("%d tokens issued, want 1", n)
+	}
+}
+
+func TestICDTokenRefreshed(t *testing.T) {
+	server := newServer(t)
+	// Tokens are renewed a minute before they expire, so these are renewed
+	// before every request.
+	server.SetTokenLifetime(time.Minute)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	for range 3 {
+		if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+	}
+
+	if n := server.TokensIssued(); n != 3 {
+		t.Errorf("%d tokens issued, want 3", n)
+	}
+}
+
+func TestICDTokenRevoked(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	// The rejected request is sent again with a new token.
+	server.RevokeTokens()
+	if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+		t.Fatalf("Search after the token was revoked: %v", err)
+	}
+
+	if n := server.TokensIssued(); n != 2 {
+		t.Errorf("%d tokens issued, want 2", n)
+	}
+	if n := server.Requests(searchPath); n != 3 {
+		t.Errorf("searched %d times, want 3", n)
+	}
+}
+
+func TestICDUnauthorizedRetriedOnce(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	server.Fail(searchPath, http.StatusUnauthorized, -1)
+
+	_, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10)
+	var upstreamErr *repository.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("Search returned %v, want an UpstreamError", err)
+	}
+	if n := server.Requests(searchPath); n != 2 {
+		t.Errorf("searched %d times, want 2", n)
+	}
+}
+
+func TestICDTokenSingleFlight(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	search := func() {
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+					t.Errorf("Search: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	search()
+	if n := server.TokensIssued(); n != 1 {
+		t.Errorf("%d tokens issued for concurrent requests, want 1", n)
+	}
+
+	// All the requests rejected with the revoked token share a new one.
+	server.RevokeTokens()
+	search()
+	if n := server.TokensIssued(); n != 2 {
+		t.Errorf("%d tokens issued after concurrent rejections, want 2", n)
+	}
+}
+
+func TestICDTokenRefreshedAhead(t *testing.T) {
+	server := newServer(t)
+	// The tokens can be used for two seconds, the last fifth of which is
+	// left to refresh them in the background.
+	server.SetTokenLifetime(time.Minute + 2*time.Second)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.TokensIssued() < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := server.TokensIssued(); n != 2 {
+		t.Fatalf("%d tokens issued, want the token in use refreshed ahead of its expiry", n)
+	}
+
+	if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if n := server.TokensIssued(); n != 2 {
+		t.Errorf("%d tokens issued, want the refreshed token used", n)
+	}
+}
+
+func TestICDBadCredentials(t *testing.T) {
+	server := newServer(t)
+
+	endpoint := server.Endpoint()
+	endpoint.ClientSecret = "wrong"
+	icdRepository, err := repository.NewICDRepository(server.Client(), endpoint, testOptions, nil, repository.ICDEdition{})
+	if err != nil {
+		t.Fatalf("NewICDRepository: %v", err)
+	}
+
+	_, _, err = icdRepository.Search(context.Background(), "cholera", 0, 10)
+	var upstreamErr *repository.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("Search with bad credentials returned %v, want an UpstreamError", err)
+	}
+	if n := server.Requests(searchPath); n != 0 {
+		t.Errorf("searched %d times without a token", n)
+	}
+}
+
+func TestICDRetriesServerErrors(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	server.Fail(searchPath, http.StatusServiceUnavailable, 1)
+	server.Fail("/connect/token", http.StatusBadGateway, 1)
+
+	matches, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("Search returned %v, want 2 matches", matches)
+	}
+	if n := server.Requests(searchPath); n != 2 {
+		t.Errorf("searched %d times, want 2", n)
+	}
+}
+
+func TestICDDoesNotRetryClientErrors(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	server.Fail(searchPath, http.StatusBadRequest, -1)
+
+	_, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10)
+	var upstreamErr *repository.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("Search returned %v, want an UpstreamError", err)
+	}
+	if n := server.Requests(searchPath); n != 1 {
+		t.Errorf("searched %d times, want 1", n)
+	}
+}
+
+func TestICDUpstreamFailure(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	server.Fail(searchPath, http.StatusInternalServerError, -1)
+
+	_, err := icdRepository.Find(context.Background(), "cholera")
+	var upstreamErr *repository.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("Find returned %v, want an UpstreamError", err)
+	}
+	if n := server.Requests(searchPath); n != 1+testOptions.Retries {
+		t.Errorf("searched %d times, want %d", n, 1+testOptions.Retries)
+	}
+}
+
+func TestICDCircuitOpens(t *testing.T) {
+	server := newServer(t)
+	options := testOptions
+	options.Retries = 0
+	options.BreakerThreshold = 2
+	options.BreakerCooldown = time.Hour
+	icdRepository := newRepository(t, server, options, nil)
+
+	// Warm the token up so that only searches count.
+	if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	server.Fail(searchPath, http.StatusInternalServerError, -1)
+	for range 2 {
+		if _, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10); err == nil {
+			t.Fatal("Search succeeded against a failing API")
+		}
+	}
+
+	_, _, err := icdRepository.Search(context.Background(), "cholera", 0, 10)
+	if !errors.Is(err, repository.ErrCircuitOpen) {
+		t.Errorf("Search returned %v, want ErrCircuitOpen", err)
+	}
+	if n := server.Requests(searchPath); n != 3 {
+		t.Errorf("searched %d times, want 3", n)
+	}
+}
+
+func TestICDCancel(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := icdRepository.Search(ctx, "cholera", 0, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("Search returned %v, want context.Canceled", err)
+	}
+}
+
+func TestICDCacheServesWithoutAPI(t *testing.T) {
+	server := newServer(t)
+
+	cache, err := repository.NewICDCache(filepath.Join(t.TempDir(), "icd-cache.db"), repository.ICDCacheOptions{})
+	if err != nil {
+		t.Fatalf("NewICDCache: %v", err)
+	}
+
+	if _, err := newRepository(t, server, testOptions, cache).Get(context.Background(), "1A00"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// A new repository, as after a restart, gets its answers from the cache
+	// alone, without even a token.
+	server.Fail(codeInfoPath+"1A00", http.StatusServiceUnavailable, -1)
+	server.Fail("/connect/token", http.StatusServiceUnavailable, -1)
+
+	match, err := newRepository(t, server, testOptions, cache).Get(context.Background(), "1A00")
+	if err != nil {
+		t.Fatalf("Get from the cache: %v", err)
+	}
+	if match.Name != "Cholera" {
+		t.Errorf("Get from the cache returned %+v", match)
+	}
+	if n := server.TokensIssued(); n != 1 {
+		t.Errorf("%d tokens issued, want 1", n)
+	}
+}
+
+func TestICDWithEdition(t *testing.T) {
+	server := newServer(t)
+	icdRepository := newRepository(t, server, testOptions, nil)
+	ctx := context.Background()
+
+	spanish, err := icdRepository.WithEdition(ctx, repository.ICDEdition{Language: "es"})
+	if err != nil {
+		t.Fatalf("WithEdition: %v", err)
+	}
+	match, err := spanish.Get(ctx, "1A00")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if match.Name != "Cólera" {
+		t.Errorf("Get in Spanish returned %q, want Cólera", match.Name)
+	}
+
+	// The release has not been translated into Hindi.
+	hindi, err := icdRepository.WithEdition(ctx, repository.ICDEdition{Language: "hi"})
+	if err != nil {
+		t.Fatalf("WithEdition: %v", err)
+	}
+	if language := hindi.Edition().Language; language != "en" {
+		t.Errorf("Hindi falls back to %q, want en", language)
+	}
+
+	if _, err := icdRepository.WithEdition(ctx, repository.ICDEdition{Release: "1999-01"}); !errors.Is(err, repository.ErrInvalidEdition) {
+		t.Errorf("WithEdition of an unknown release returned %v, want ErrInvalidEdition", err)
+	}
+	if _, err := icdRepository.WithEdition(ctx, repository.ICDEdition{Release: "../x"}); !errors.Is(err, repository.ErrInvalidEdition) {
+		t.Errorf("WithEdition of a malformed release returned %v, want ErrInvalidEdition", err)
+	}
+}
@@ -0,0 +1,308 @@
+// Package icdapitest provides a stand-in for the WHO ICD API, for tests that
+// cannot call WHO. It issues tokens like the WHO access management server
+// and answers the search, entity and codeinfo requests of a release from
+// fixtures of a few ICD-11 entities, in the shape the live API answers with.
+package icdapitest
+
+import (
+	"backend/internal/repository"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The credentials the server issues tokens for.
+const (
+	ClientID     = "icdapitest-client"
+	ClientSecret = "icdapitest-secret"
+)
+
+// tokenPath is the path of the token endpoint, and releasePrefix the prefix
+// of the paths of the ICD API the fixtures are served under.
+const (
+	tokenPath     = "/connect/token"
+	releasePrefix = "/icd/release/11/"
+)
+
+// fixtures holds the responses of the server, at the path of the request
+// below releasePrefix with a .json extension. A response in another
+// language than English has the language before the extension. A search
+// is answered from search/<query>.json.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// Server is a running stand-in for the WHO ICD API. Both the token endpoint
+// and the API are served from its URL.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	tokenLifetime time.Duration
+	tokens        map[string]time.Time
+	issued        int
+	requests      map[string]int
+	faults        map[string]*fault
+}
+
+// fault is a status the server answers with instead of serving a path.
+type fault struct {
+	status int
+	// times is how many more requests get the status. Negative is forever.
+	times int
+}
+
+// NewServer starts a server issuing tokens that last an hour. It must be
+// closed when the test is done.
+func NewServer() *Server {
+	s := &Server{
+		tokenLifetime: time.Hour,
+		tokens:        make(map[string]time.Time),
+		requests:      make(map[string]int),
+		faults:        make(map[string]*fault),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPath, s.token)
+	mux.HandleFunc(releasePrefix, s.api)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Endpoint returns the endpoint to reach the server with, with the
+// credentials it accepts.
+func (s *Server) Endpoint() repository.WHOEndpoint {
+	return repository.WHOEndpoint{
+		APIURL:       s.URL,
+		TokenURL:     s.URL + tokenPath,
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+	}
+}
+
+// SetTokenLifetime sets how long the tokens issued from now on last. The
+// server reports it as expires_in, and rejects the tokens once it is over.
+func (s *Server) SetTokenLifetime(lifetime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenLifetime = lifetime
+}
+
+// RevokeTokens makes the server reject the tokens issued so far, as WHO does
+// when it rotates its keys.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.tokens)
+}
+
+// TokensIssued returns the number of tokens issued so far.
+func (s *Server) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issued
+}
+
+// Requests returns the number of requests received for urlPath, such as
+// "/icd/release/11/2025-01/mms/search", whatever their outcome.
+func (s *Server) Requests(urlPath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[urlPath]
+}
+
+// Fail makes the next times requests for urlPath get status instead of
+// their response. A negative times fails them until Fail is called again
+// for urlPath, and zero stops failing them.
+func (s *Server) Fail(urlPath string, status int, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if times == 0 {
+		delete(s.faults, urlPath)
+		return
+	}
+	s.faults[urlPath] = &fault{status: status, times: times}
+}
+
+// received counts a request for urlPath, and returns the status of a fault
+// that fails it, if any.
+func (s *Server) received(urlPath string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[urlPath]++
+
+	f, ok := s.faults[urlPath]
+	if !ok {
+		return 0, false
+	}
+	if f.times > 0 {
+		f.times--
+		if f.times == 0 {
+			delete(s.faults, urlPath)
+		}
+	}
+
+	return f.status, true
+}
+
+// token answers an OAuth2 client credentials grant.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if status, failed := s.received(r.URL.Path); failed {
+		w.WriteHeader(status)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if r.PostForm.Get("client_id") != ClientID || r.PostForm.Get("client_secret") != ClientSecret {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("scope") != "icdapi_access" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_scope"})
+		return
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	accessToken := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	lifetime := s.tokenLifetime
+	s.tokens[accessToken] = time.Now().Add(lifetime)
+	s.issued++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"expires_in":   int(lifetime.Seconds()),
+		"token_type":   "Bearer",
+		"scope":        "icdapi_access",
+	})
+}
+
+// api answers a request to the ICD API from the fixtures, once its token
+// and headers have been checked.
+func (s *Server) api(w http.ResponseWriter, r *http.Request) {
+	if status, failed := s.received(r.URL.Path); failed {
+		w.WriteHeader(status)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	expiry, issued := s.tokens[accessToken]
+	s.mu.Unlock()
+	if !ok || !issued || time.Now().After(expiry) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("API-Version") != "v2" {
+		http.Error(w, "API-Version header is required", http.StatusBadRequest)
+		return
+	}
+
+	name := path.Clean(strings.TrimPrefix(r.URL.Path, releasePrefix))
+	language := r.Header.Get("Accept-Language")
+
+	if path.Base(name) == "search" {
+		s.search(w, name, language, r.URL.Query().Get("q"), r.URL.Query().Get("chapterFilter"))
+		return
+	}
+
+	body, ok := fixture(name, language)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// search answers a search from the fixture of its query, with the results
+// outside the chapters of chapterFilter left out. A query without a fixture
+// finds nothing.
+func (s *Server) search(w http.ResponseWriter, name string, language string, q string, chapterFilter string) {
+	var response map[string]any
+	if body, ok := fixture(path.Join(name, strings.ToLower(q)), language); ok {
+		if err := json.Unmarshal(body, &response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		response = map[string]any{"error": false, "destinationEntities": []any{}}
+	}
+
+	if chapterFilter != "" {
+		chapters := strings.Split(chapterFilter, ";")
+		entities, _ := response["destinationEntities"].([]any)
+		response["destinationEntities"] = slices.DeleteFunc(entities, func(entity any) bool {
+			chapter, _ := entity.(map[string]any)["chapter"].(string)
+			return !slices.Contains(chapters, chapter)
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// fixture returns the fixture at name in language, or in English if there
+// is no translation.
+func fixture(name string, language string) ([]byte, bool) {
+	if strings.Contains(name, "..") {
+		return nil, false
+	}
+
+	candidates := []string{name + ".json"}
+	if language != "" && language != "en" {
+		candidates = slices.Insert(candidates, 0, name+"."+language+".json")
+	}
+
+	for _, candidate := range candidates {
+		body, err := fs.ReadFile(fixtures, path.Join("fixtures", candidate))
+		if err == nil {
+			return body, true
+		}
+	}
+
+	return nil, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
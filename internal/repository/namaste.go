@@ -1,20 +1,34 @@
 package repository
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
 	"github.com/blevesearch/bleve/search/query"
 )
 
+// Branches lists the traditional medicine systems covered by NAMASTE, in
+// the order they are indexed.
+var Branches = []string{"ayurveda", "unani", "siddha"}
+
 type NamasteMatch struct {
 	Type string
 	ID   string
 	Name string
 	Desc string
+	// ShortDesc is the English gloss of the term, such as "Hepatic disease".
+	ShortDesc string
 }
 
 type NamasteMatches struct {
@@ -33,31 +47,122 @@ type Record struct {
 }
 
 type NamasteRepository interface {
-	CreateIndex(path string) error
-	Find(input string) (*NamasteMatches, error)
-	List(size int) ([]NamasteMatch, error)
+	// CreateIndex rebuilds the index from the NAMASTE CSV files and swaps it
+	// in once it is complete, so searches keep being served meanwhile.
+	CreateIndex() error
+	// Find returns the best matches of input. Native terms are searched in
+	// the script of lang, or of input if lang is empty.
+	Find(ctx context.Context, input string, lang string) (*NamasteMatches, error)
+	List(ctx context.Context, size int) ([]NamasteMatch, error)
+	Lookup(ctx context.Context, code string) ([]Record, error)
+	Search(ctx context.Context, input string, branch string, offset int, size int) ([]Record, uint64, error)
+	// Suggest returns the records whose terms complete or nearly match the
+	// partial input, best first, restricted to branch unless branch is empty.
+	Suggest(ctx context.Context, input string, lang string, branch string, size int) ([]Record, error)
+}
+
+// namasteRepository searches through an alias of the current index. Every
+// rebuild goes to a new directory next to path, named after its generation
+// once it is complete, and the alias is swapped to it, so a search never
+// sees a partial index.
+type namasteRepository struct {
+	path string
+	// assets is the directory of the NAMASTE CSV files.
+	assets string
+	alias  bleve.IndexAlias
+
+	// mu serializes rebuilds and guards current.
+	mu      sync.Mutex
+	current bleve.Index
+	dir     string
 }
 
-type namasteRepository struct{}
+// NewNamasteRepository opens the newest index generation of path, or builds
+// one from the CSV files in assets if there is none yet.
+func NewNamasteRepository(path string, assets string) (NamasteRepository, error) {
+	n := &namasteRepository{
+		path:   path,
+		assets: assets,
+		alias:  bleve.NewIndexAlias(),
+	}
+
+	// Generations are named after the time they were built, so the newest
+	// sorts first. Older ones, and the rebuilds a crash left unfinished, are
+	// deleted.
+	dirs, _ := filepath.Glob(path + ".*")
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		if !isIndexGeneration(path, dir) {
+			if strings.HasSuffix(dir, buildSuffix) {
+				os.RemoveAll(dir)
+			}
+			continue
+		}
 
-func NewNamasteRepository() NamasteRepository {
-	return &namasteRepository{}
+		if n.current != nil {
+			os.RemoveAll(dir)
+			continue
+		}
+
+		index, err := bleve.Open(dir)
+		if err != nil {
+			log.Printf("Error: unable to open index %s: %s", dir, err)
+			continue
+		}
+
+		n.swap(index, dir)
+	}
+
+	if n.current == nil {
+		if err := n.CreateIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
 }
 
-func (n *namasteRepository) List(size int) ([]NamasteMatch, error) {
-	index, err := bleve.Open("index.bleve")
-	if err != nil {
-		return nil, fmt.Errorf("unable to open index: %w", err)
+// buildSuffix marks the directory of an index generation being built.
+const buildSuffix = ".tmp"
+
+// isIndexGeneration reports whether dir is an index generation of path.
+func isIndexGeneration(path string, dir string) bool {
+	suffix, ok := strings.CutPrefix(dir, path+".")
+	if !ok {
+		return false
 	}
-	defer index.Close()
 
+	_, err := strconv.ParseInt(suffix, 10, 64)
+	return err == nil
+}
+
+// swap makes index the one searched, then closes and deletes the previous
+// one. The alias waits for running searches before swapping, so none of them
+// uses the previous index once swap returns.
+func (n *namasteRepository) swap(index bleve.Index, dir string) {
+	previous, previousDir := n.current, n.dir
+
+	if previous == nil {
+		n.alias.Add(index)
+	} else {
+		n.alias.Swap([]bleve.Index{index}, []bleve.Index{previous})
+	}
+	n.current, n.dir = index, dir
+
+	if previous != nil {
+		previous.Close()
+		os.RemoveAll(previousDir)
+	}
+}
+
+func (n *namasteRepository) List(ctx context.Context, size int) ([]NamasteMatch, error) {
 	matchQuery := query.NewMatchAllQuery()
 
 	searchRequest := bleve.NewSearchRequest(matchQuery)
 	searchRequest.Size = size
-	searchRequest.Fields = []string{"Type", "Code", "Diacritical", "LongDesc"}
+	searchRequest.Fields = []string{"Type", "Code", "Diacritical", "LongDesc", "ShortDesc"}
 
-	searchResult, err := index.Search(searchRequest)
+	searchResult, err := n.alias.SearchInContext(ctx, searchRequest)
 	if err != nil {
 		return nil, fmt.Errorf("unable to search: %w", err)
 	}
@@ -68,12 +173,14 @@ func (n *namasteRepository) List(size int) ([]NamasteMatch, error) {
 		code := hit.Fields["Code"].(string)
 		name := hit.Fields["Diacritical"].(string)
 		desc := hit.Fields["LongDesc"].(string)
+		shortDesc, _ := hit.Fields["ShortDesc"].(string)
 
 		matches = append(matches, NamasteMatch{
-			Type: typ,
-			ID:   code,
-			Name: name,
-			Desc: desc,
+			Type:      typ,
+			ID:        code,
+			Name:      name,
+			Desc:      desc,
+			ShortDesc: shortDesc,
 		})
 	}
 
@@ -81,24 +188,52 @@ func (n *namasteRepository) List(size int) ([]NamasteMatch, error) {
 }
 
 // CreateIndex implements NamasteRepository.
-func (n *namasteRepository) CreateIndex(path string) error {
-	// Define 3 branches of traditional medicine
-	branches := []string{"ayurveda", "unani", "siddha"}
+func (n *namasteRepository) CreateIndex() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	dir := fmt.Sprintf("%s.%d", n.path, time.Now().UnixNano())
+	buildDir := dir + buildSuffix
 
-	// Delete the old index
-	os.RemoveAll(path)
+	mapping, err := namasteIndexMapping()
+	if err != nil {
+		return err
+	}
 
-	mapping := bleve.NewIndexMapping()
-	index, err := bleve.New(path, mapping)
+	index, err := bleve.New(buildDir, mapping)
 	if err != nil {
 		return fmt.Errorf("error creating bleve index: %w", err)
 	}
-	defer index.Close()
 
+	err = indexBranches(index, n.assets)
+	if closeErr := index.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(buildDir)
+		return err
+	}
+
+	// Only a complete index is named as a generation.
+	if err := os.Rename(buildDir, dir); err != nil {
+		os.RemoveAll(buildDir)
+		return fmt.Errorf("unable to rename index: %w", err)
+	}
+
+	index, err = bleve.Open(dir)
+	if err != nil {
+		return fmt.Errorf("unable to open index %s: %w", dir, err)
+	}
+
+	n.swap(index, dir)
+	return nil
+}
+
+// indexBranches indexes the records of every branch's CSV file in assets.
+func indexBranches(index bleve.Index, assets string) error {
 	log.Println("Starting to index documents...")
-	for _, branch := range branches {
-		// Get the traditional medicine csv from assets
-		file, err := os.Open("assets/" + branch + ".csv")
+	for _, branch := range Branches {
+		file, err := os.Open(filepath.Join(assets, branch+".csv"))
 		if err != nil {
 			return fmt.Errorf("error opening asset: %w", err)
 		}
@@ -110,6 +245,7 @@ func (n *namasteRepository) CreateIndex(path string) error {
 			return fmt.Errorf("error reading CSV records from %s: %w", branch, err)
 		}
 
+		batch := index.NewBatch()
 		for i, recordCSV := range records {
 			if i == 0 { // Skip header row
 				continue
@@ -152,12 +288,17 @@ func (n *namasteRepository) CreateIndex(path string) error {
 				continue
 			}
 
-			if err := index.Index(record.Term, record); err != nil {
+			record.Code = strings.TrimSpace(record.Code)
+			if err := batch.Index(documentID(record.Type, record.Code), record); err != nil {
 				fmt.Println(record)
 				return fmt.Errorf("unable to index document %s: %w", record.ID, err)
 			}
 		}
 
+		if err := index.Batch(batch); err != nil {
+			return fmt.Errorf("unable to index %s branch: %w", branch, err)
+		}
+
 		log.Printf("Successfully indexed %s branch.", branch)
 	}
 
@@ -165,21 +306,18 @@ func (n *namasteRepository) CreateIndex(path string) error {
 	return nil
 }
 
-func (n *namasteRepository) Find(input string) (*NamasteMatches, error) {
-	index, err := bleve.Open("index.bleve")
+func (n *namasteRepository) Find(ctx context.Context, input string, lang string) (*NamasteMatches, error) {
+	matchQuery, err := textQuery(input, lang)
 	if err != nil {
-		return nil, fmt.Errorf("unable to open index: %w", err)
+		return nil, err
 	}
-	defer index.Close()
-
-	matchQuery := query.NewMatchQuery(input)
 
 	searchRequest := bleve.NewSearchRequest(matchQuery)
 	searchRequest.Size = 5 // Get top 5 results
 	// We are only concerned with these fields
-	searchRequest.Fields = []string{"Type", "Code", "Diacritical", "LongDesc"}
+	searchRequest.Fields = []string{"Type", "Code", "Diacritical", "LongDesc", "ShortDesc"}
 
-	searchResult, err := index.Search(searchRequest)
+	searchResult, err := n.alias.SearchInContext(ctx, searchRequest)
 	if err != nil {
 		return nil, fmt.Errorf("unable to search: %w", err)
 	}
@@ -190,12 +328,14 @@ func (n *namasteRepository) Find(input string) (*NamasteMatches, error) {
 		code := hit.Fields["Code"].(string)
 		name := hit.Fields["Diacritical"].(string)
 		desc := hit.Fields["LongDesc"].(string)
+		shortDesc, _ := hit.Fields["ShortDesc"].(string)
 
 		matches = append(matches, NamasteMatch{
-			Type: typ,
-			ID:   code,
-			Name: name,
-			Desc: desc,
+			Type:      typ,
+			ID:        code,
+			Name:      name,
+			Desc:      desc,
+			ShortDesc: shortDesc,
 		})
 	}
 
@@ -203,3 +343,128 @@ func (n *namasteRepository) Find(input string) (*NamasteMatches, error) {
 		matches,
 	}, nil
 }
+
+// Lookup implements NamasteRepository. Codes are only unique within a branch,
+// so every branch's record for the code is returned.
+func (n *namasteRepository) Lookup(ctx context.Context, code string) ([]Record, error) {
+	ids := make([]string, 0, len(Branches))
+	for _, branch := range Branches {
+		ids = append(ids, documentID(branch, strings.TrimSpace(code)))
+	}
+
+	searchRequest := bleve.NewSearchRequest(query.NewDocIDQuery(ids))
+	searchRequest.Size = len(ids)
+	searchRequest.Fields = []string{"*"}
+
+	searchResult, err := n.alias.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search: %w", err)
+	}
+
+	if len(searchResult.Hits) == 0 {
+		return nil, ErrNotFound
+	}
+
+	records := make([]Record, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		records = append(records, recordFromHit(hit))
+	}
+
+	return records, nil
+}
+
+// Search implements NamasteRepository. It returns one page of the records
+// matching input, or of every record if input is empty, restricted to branch
+// unless branch is empty, along with the total number of matches.
+func (n *namasteRepository) Search(ctx context.Context, input string, branch string, offset int, size int) ([]Record, uint64, error) {
+	conjuncts := make([]query.Query, 0, 2)
+	if input != "" {
+		textMatch, err := textQuery(input, "")
+		if err != nil {
+			return nil, 0, err
+		}
+		conjuncts = append(conjuncts, textMatch)
+	} else {
+		conjuncts = append(conjuncts, query.NewMatchAllQuery())
+	}
+
+	if branch != "" {
+		branchQuery := query.NewTermQuery(branch)
+		branchQuery.SetField("Type")
+		conjuncts = append(conjuncts, branchQuery)
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(query.NewConjunctionQuery(conjuncts), size, offset, false)
+	searchRequest.Fields = []string{"*"}
+	if input == "" {
+		// Without a query every hit scores the same, so order by key to keep
+		// pages stable.
+		searchRequest.SortBy([]string{"_id"})
+	}
+
+	searchResult, err := n.alias.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to search: %w", err)
+	}
+
+	records := make([]Record, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		records = append(records, recordFromHit(hit))
+	}
+
+	return records, searchResult.Total, nil
+}
+
+// Suggest implements NamasteRepository.
+func (n *namasteRepository) Suggest(ctx context.Context, input string, lang string, branch string, size int) ([]Record, error) {
+	typeahead, err := typeaheadQuery(input, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	conjuncts := []query.Query{typeahead}
+	if branch != "" {
+		branchQuery := query.NewTermQuery(branch)
+		branchQuery.SetField("Type")
+		conjuncts = append(conjuncts, branchQuery)
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(query.NewConjunctionQuery(conjuncts), size, 0, false)
+	searchRequest.Fields = []string{"*"}
+
+	searchResult, err := n.alias.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search: %w", err)
+	}
+
+	records := make([]Record, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		records = append(records, recordFromHit(hit))
+	}
+
+	return records, nil
+}
+
+// documentID is the key a record is indexed under. NAMASTE codes repeat
+// across branches, so the branch is part of the key.
+func documentID(branch, code string) string {
+	return branch + "/" + code
+}
+
+func recordFromHit(hit *search.DocumentMatch) Record {
+	field := func(name string) string {
+		value, _ := hit.Fields[name].(string)
+		return value
+	}
+
+	return Record{
+		Type:        field("Type"),
+		ID:          field("ID"),
+		Code:        field("Code"),
+		Term:        field("Term"),
+		Diacritical: field("Diacritical"),
+		Native:      field("Native"),
+		ShortDesc:   field("ShortDesc"),
+		LongDesc:    field("LongDesc"),
+	}
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	_ "github.com/blevesearch/bleve/analysis/lang/ar"
+	_ "github.com/blevesearch/bleve/analysis/lang/fa"
+	_ "github.com/blevesearch/bleve/analysis/lang/in"
+	"github.com/blevesearch/bleve/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/token/unicodenorm"
+	unicodetokenizer "github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+var ErrUnknownLanguage = errors.New("unsupported search language")
+
+// Analyzers of the native terms, one per script.
+const (
+	devanagariAnalyzer = "devanagari"
+	tamilAnalyzer      = "tamil"
+	arabicAnalyzer     = "arabic"
+)
+
+// analyzerFilters holds the filters of a custom analyzer. Every analyzer of
+// the index uses the unicode tokenizer.
+type analyzerFilters struct {
+	charFilters  []string
+	tokenFilters []string
+}
+
+// prefixFilterName is the filter that indexes the leading edge n-grams of
+// each word.
+const prefixFilterName = "prefix_ngram"
+
+// Analyzers of the transliterated terms. The ITRANS ones read the case
+// conventions of the Ayurveda terms, and the phonetic ones index the phonetic
+// key of each word in the Phonetic field.
+const (
+	transliterationAnalyzer = "transliteration"
+	itransAnalyzer          = "itrans"
+	phoneticAnalyzer        = "phonetic"
+	itransPhoneticAnalyzer  = "itrans_phonetic"
+)
+
+// branchAnalyzers holds the analyzer of the native term of each branch:
+// Ayurveda terms are in Devanagari, Siddha terms in Tamil and Unani terms in
+// the Arabic script of Urdu.
+var branchAnalyzers = map[string]string{
+	"ayurveda": devanagariAnalyzer,
+	"siddha":   tamilAnalyzer,
+	"unani":    arabicAnalyzer,
+}
+
+// languageAnalyzers holds the analyzer used for a query in each supported
+// language. English means the transliterated terms only.
+var languageAnalyzers = map[string]string{
+	"en": "",
+	"sa": devanagariAnalyzer,
+	"hi": devanagariAnalyzer,
+	"mr": devanagariAnalyzer,
+	"ta": tamilAnalyzer,
+	"ur": arabicAnalyzer,
+	"ar": arabicAnalyzer,
+	"fa": arabicAnalyzer,
+}
+
+// namasteIndexMapping maps each branch as a document type of its own, keyed
+// by Type, so that its native term is analyzed for its script.
+func namasteIndexMapping() (mapping.IndexMapping, error) {
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.TypeField = "Type"
+
+	err := indexMapping.AddCustomTokenFilter("nfkc", map[string]interface{}{
+		"type": unicodenorm.Name,
+		"form": unicodenorm.NFKC,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to add token filter: %w", err)
+	}
+
+	err = indexMapping.AddCustomTokenFilter(prefixFilterName, map[string]interface{}{
+		"type": edgengram.Name,
+		"back": false,
+		"min":  2.0,
+		"max":  20.0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to add token filter: %w", err)
+	}
+
+	// Devanagari and Tamil share the Indic normalization, which unifies the
+	// different ways of typing the same letter.
+	analyzers := map[string]analyzerFilters{
+		devanagariAnalyzer:      {tokenFilters: []string{"nfkc", lowercase.Name, "normalize_in"}},
+		tamilAnalyzer:           {tokenFilters: []string{"nfkc", lowercase.Name, "normalize_in"}},
+		arabicAnalyzer:          {tokenFilters: []string{"nfkc", lowercase.Name, "normalize_ar", "normalize_fa"}},
+		transliterationAnalyzer: {charFilters: []string{foldFilterName}},
+		itransAnalyzer:          {charFilters: []string{itransFilterName, foldFilterName}},
+		phoneticAnalyzer:        {charFilters: []string{foldFilterName}, tokenFilters: []string{phoneticFilterName}},
+		itransPhoneticAnalyzer:  {charFilters: []string{itransFilterName, foldFilterName}, tokenFilters: []string{phoneticFilterName}},
+	}
+
+	// The terms are also indexed by the leading edge of each word in the
+	// Prefix field, for typeahead.
+	for _, name := range []string{devanagariAnalyzer, tamilAnalyzer, arabicAnalyzer, transliterationAnalyzer, itransAnalyzer} {
+		filters := analyzers[name]
+		analyzers[prefixAnalyzer(name)] = analyzerFilters{
+			charFilters:  filters.charFilters,
+			tokenFilters: append(slices.Clone(filters.tokenFilters), prefixFilterName),
+		}
+	}
+
+	// Filters are only set when there are some, as an index whose mapping
+	// holds a null list of filters cannot be opened again.
+	for name, filters := range analyzers {
+		analyzer := map[string]interface{}{
+			"type":      custom.Name,
+			"tokenizer": unicodetokenizer.Name,
+		}
+		if len(filters.charFilters) > 0 {
+			analyzer["char_filters"] = filters.charFilters
+		}
+		if len(filters.tokenFilters) > 0 {
+			analyzer["token_filters"] = filters.tokenFilters
+		}
+
+		err := indexMapping.AddCustomAnalyzer(name, analyzer)
+		if err != nil {
+			return nil, fmt.Errorf("unable to add %s analyzer: %w", name, err)
+		}
+	}
+
+	mapTransliterations(indexMapping.DefaultMapping, false)
+	for branch, analyzer := range branchAnalyzers {
+		native := bleve.NewTextFieldMapping()
+		native.Analyzer = analyzer
+
+		documentMapping := bleve.NewDocumentMapping()
+		documentMapping.AddFieldMappingsAt("Native", native, derivedField("Prefix", prefixAnalyzer(analyzer)))
+		mapTransliterations(documentMapping, branch == "ayurveda")
+		indexMapping.AddDocumentMapping(branch, documentMapping)
+	}
+
+	return indexMapping, nil
+}
+
+// mapTransliterations maps the transliterated terms of a document, with
+// their phonetic keys in Phonetic and their prefixes in Prefix. Only the
+// Ayurveda Term is in ITRANS.
+func mapTransliterations(documentMapping *mapping.DocumentMapping, itrans bool) {
+	termAnalyzer, termPhoneticAnalyzer := transliterationAnalyzer, phoneticAnalyzer
+	if itrans {
+		termAnalyzer, termPhoneticAnalyzer = itransAnalyzer, itransPhoneticAnalyzer
+	}
+
+	term := bleve.NewTextFieldMapping()
+	term.Analyzer = termAnalyzer
+	documentMapping.AddFieldMappingsAt("Term", term,
+		derivedField("Phonetic", termPhoneticAnalyzer),
+		derivedField("Prefix", prefixAnalyzer(termAnalyzer)))
+
+	diacritical := bleve.NewTextFieldMapping()
+	diacritical.Analyzer = transliterationAnalyzer
+	documentMapping.AddFieldMappingsAt("Diacritical", diacritical,
+		derivedField("Phonetic", phoneticAnalyzer),
+		derivedField("Prefix", prefixAnalyzer(transliterationAnalyzer)))
+}
+
+// derivedField indexes a field again under name with another analyzer, only
+// for searching.
+func derivedField(name string, analyzer string) *mapping.FieldMapping {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Name = name
+	fieldMapping.Analyzer = analyzer
+	fieldMapping.Store = false
+	fieldMapping.IncludeInAll = false
+	fieldMapping.IncludeTermVectors = false
+	return fieldMapping
+}
+
+// prefixAnalyzer returns the name of the prefix variant of an analyzer.
+func prefixAnalyzer(analyzer string) string {
+	return analyzer + "_prefix"
+}
+
+// textQuery matches input against the terms and definitions of a record,
+// and against its native term when input is in a native script.
+func textQuery(input string, lang string) (query.Query, error) {
+	analyzer, err := nativeAnalyzer(input, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exact transliterations score above words that only sound alike.
+	queries := []query.Query{
+		query.NewMatchQuery(input),
+		fieldQuery(input, "Term", transliterationAnalyzer, 2),
+		fieldQuery(input, "Diacritical", transliterationAnalyzer, 2),
+		fieldQuery(input, "Phonetic", phoneticAnalyzer, 0.5),
+	}
+
+	if analyzer != "" {
+		queries = append(queries, fieldQuery(input, "Native", analyzer, 2))
+	}
+
+	return query.NewDisjunctionQuery(queries), nil
+}
+
+// typeaheadQuery ranks whole words over prefixes over typos of input.
+func typeaheadQuery(input string, lang string) (query.Query, error) {
+	analyzer, err := nativeAnalyzer(input, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	// A disjunction favours hits matching more clauses, so each kind of
+	// match is one clause, boosted far apart to outweigh field lengths.
+	exact := []query.Query{
+		fieldQuery(input, "Term", transliterationAnalyzer, 8),
+		fieldQuery(input, "Diacritical", transliterationAnalyzer, 8),
+	}
+	prefix := []query.Query{fieldQuery(input, "Prefix", transliterationAnalyzer, 4)}
+	var fuzzy []query.Query
+
+	fuzziness := typoDistance(input)
+	if fuzziness > 0 {
+		fuzzy = append(fuzzy,
+			fuzzyQuery(input, "Term", transliterationAnalyzer, fuzziness),
+			fuzzyQuery(input, "Diacritical", transliterationAnalyzer, fuzziness))
+	}
+
+	if analyzer != "" {
+		exact = append(exact, fieldQuery(input, "Native", analyzer, 8))
+		prefix = append(prefix, fieldQuery(input, "Prefix", analyzer, 4))
+		if fuzziness > 0 {
+			fuzzy = append(fuzzy, fuzzyQuery(input, "Native", analyzer, fuzziness))
+		}
+	}
+
+	queries := []query.Query{
+		query.NewDisjunctionQuery(exact),
+		query.NewDisjunctionQuery(prefix),
+		fieldQuery(input, "Phonetic", phoneticAnalyzer, 1),
+	}
+	if len(fuzzy) > 0 {
+		queries = append(queries, query.NewDisjunctionQuery(fuzzy))
+	}
+
+	return query.NewDisjunctionQuery(queries), nil
+}
+
+// typoDistance returns the number of typos tolerated in input. Short inputs
+// get none, as any word would be a couple of edits away.
+func typoDistance(input string) int {
+	switch n := len([]rune(input)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzyQuery matches words within fuzziness edits of input, keeping the
+// first letter.
+func fuzzyQuery(input string, field string, analyzer string, fuzziness int) query.Query {
+	match := query.NewMatchQuery(input)
+	match.SetField(field)
+	match.Analyzer = analyzer
+	match.SetFuzziness(fuzziness)
+	match.SetPrefix(1)
+	match.SetBoost(0.25)
+	return match
+}
+
+// nativeAnalyzer returns the analyzer of the native script input is in, taken
+// from lang or, if lang is empty, guessed from the letters of input. It is
+// empty for input in the Latin script.
+func nativeAnalyzer(input string, lang string) (string, error) {
+	if lang == "" {
+		return scriptAnalyzer(input), nil
+	}
+
+	analyzer, ok := languageAnalyzers[lang]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownLanguage, lang)
+	}
+
+	return analyzer, nil
+}
+
+func fieldQuery(input string, field string, analyzer string, boost float64) query.Query {
+	match := query.NewMatchQuery(input)
+	match.SetField(field)
+	match.Analyzer = analyzer
+	match.SetBoost(boost)
+	return match
+}
+
+// scriptAnalyzer returns the analyzer of the first native script letter of
+// input, or an empty string if input is in the Latin script.
+func scriptAnalyzer(input string) string {
+	for _, r := range input {
+		switch {
+		case unicode.Is(unicode.Devanagari, r):
+			return devanagariAnalyzer
+		case unicode.Is(unicode.Tamil, r):
+			return tamilAnalyzer
+		case unicode.Is(unicode.Arabic, r):
+			return arabicAnalyzer
+		}
+	}
+
+	return ""
+}
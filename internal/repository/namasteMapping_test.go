@@ -0,0 +1,25 @@
+package repository
+
+import "testing"
+
+func TestTypoDistance(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"kas", 0},
+		{"kasa", 1},
+		{"kasarog", 1},
+		{"kasaroga", 2},
+		{"kasarogam", 2},
+		// Letters are counted, not bytes.
+		{"ज्वर", 1},
+	}
+
+	for _, test := range tests {
+		if got := typoDistance(test.input); got != test.want {
+			t.Errorf("typoDistance(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package repository_test
+
+import (
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+)
+
+const (
+	namasteAssets = "testdata/namaste"
+	// namasteRecords is the number of records in namasteAssets.
+	namasteRecords = 12
+)
+
+func newNamasteRepository(t *testing.T, path string) repository.NamasteRepository {
+	t.Helper()
+
+	namasteRepository, err := repository.NewNamasteRepository(path, namasteAssets)
+	if err != nil {
+		t.Fatalf("NewNamasteRepository: %v", err)
+	}
+	return namasteRepository
+}
+
+func countRecords(t *testing.T, namasteRepository repository.NamasteRepository) uint64 {
+	t.Helper()
+
+	_, total, err := namasteRepository.Search(context.Background(), "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	return total
+}
+
+func TestNamasteCreateIndexKeepsServing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.bleve")
+	namasteRepository := newNamasteRepository(t, path)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			_, total, err := namasteRepository.Search(context.Background(), "", "", 0, 0)
+			if err != nil || total != namasteRecords {
+				t.Errorf("Search during CreateIndex = %d, %v, want %d records", total, err, namasteRecords)
+				return
+			}
+		}
+	}()
+
+	for range 2 {
+		if err := namasteRepository.CreateIndex(); err != nil {
+			t.Errorf("CreateIndex: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// Only the last generation is kept.
+	dirs, _ := filepath.Glob(path + ".*")
+	if len(dirs) != 1 {
+		t.Errorf("index directories = %v, want one", dirs)
+	}
+}
+
+func TestNamasteReopenSkipsUnfinishedIndex(t *testing.T) {
+	dir := t.TempDir()
+	newNamasteRepository(t, filepath.Join(dir, "built.bleve"))
+
+	complete, _ := filepath.Glob(filepath.Join(dir, "built.bleve.*"))
+	if len(complete) != 1 {
+		t.Fatalf("index directories = %v, want one", complete)
+	}
+
+	// The open index cannot be opened twice, so a copy of it is reopened,
+	// next to a rebuild that crashed half way and sorts as newer.
+	path := filepath.Join(dir, "index.bleve")
+	generation := path + filepath.Ext(complete[0])
+	if err := os.CopyFS(generation, os.DirFS(complete[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	unfinished := path + ".9999999999999999999.tmp"
+	partial, err := bleve.New(unfinished, bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := partial.Index("ayurveda/AAA-1", map[string]string{"Type": "ayurveda", "Code": "AAA-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := partial.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	namasteRepository := newNamasteRepository(t, path)
+
+	if total := countRecords(t, namasteRepository); total != namasteRecords {
+		t.Errorf("reopened index has %d records, want %d", total, namasteRecords)
+	}
+	if _, err := os.Stat(generation); err != nil {
+		t.Errorf("complete index deleted: %v", err)
+	}
+	if _, err := os.Stat(unfinished); !os.IsNotExist(err) {
+		t.Errorf("unfinished index left behind: %v", err)
+	}
+}
+
+func recordCodes(records []repository.Record) []string {
+	codes := make([]string, 0, len(records))
+	for _, record := range records {
+		codes = append(codes, record.Type+"/"+record.Code)
+	}
+	return codes
+}
+
+func TestNamasteSuggest(t *testing.T) {
+	namasteRepository := newNamasteRepository(t, filepath.Join(t.TempDir(), "index.bleve"))
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		// unordered is set when the records score the same.
+		unordered bool
+	}{
+		{"prefix", "jvar", []string{"ayurveda/AAA-1"}, false},
+		{"one typo", "jvaeah", []string{"ayurveda/AAA-1"}, false},
+		{"one typo in four letters", "kasu", []string{"siddha/SB-1", "siddha/SB-3"}, true},
+		{"no typo in three letters", "ksi", []string{}, false},
+		{"two typos", "kasarigan", []string{"siddha/SB-2"}, false},
+		{"three typos", "kasarigzn", []string{}, false},
+		// Kasa is the word, Kasarogam begins with it and Kasi is a typo away.
+		{"exact, then prefix, then typo", "kasa", []string{"siddha/SB-1", "siddha/SB-2", "siddha/SB-3"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := namasteRepository.Suggest(context.Background(), test.input, "", "", 10)
+			if err != nil {
+				t.Fatalf("Suggest: %v", err)
+			}
+			codes := recordCodes(records)
+			if test.unordered {
+				slices.Sort(codes)
+			}
+			if !slices.Equal(codes, test.want) {
+				t.Errorf("Suggest(%q) = %v, want %v", test.input, codes, test.want)
+			}
+		})
+	}
+}
+
+func TestNamasteSearchNativeScript(t *testing.T) {
+	namasteRepository := newNamasteRepository(t, filepath.Join(t.TempDir(), "index.bleve"))
+
+	tests := []struct {
+		name  string
+		input string
+		lang  string
+		want  string
+	}{
+		// ज़ is stored precomposed, and NFKC decomposes both.
+		{"Devanagari nukta", "ज़ुकाम", "", "ayurveda/AAA-3"},
+		// The Indic normalization reads अ and ा as आ.
+		{"Devanagari vowel", "अामवात", "hi", "ayurveda/AAA-4"},
+		{"Tamil", "இருமல்", "", "siddha/SB-4"},
+		{"Tamil by language", "சுரம்", "ta", "siddha/AAA-1"},
+		// The Arabic normalization drops the damma.
+		{"Arabic harakat", "سُعال", "", "unani/UA-1"},
+		// The Persian normalization reads the Arabic kaf as the Urdu keheh.
+		{"Arabic kaf", "زكام", "ur", "unani/UA-3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches, err := namasteRepository.Find(context.Background(), test.input, test.lang)
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+			if len(matches.Diseases) != 1 || matches.Diseases[0].Type+"/"+matches.Diseases[0].ID != test.want {
+				t.Errorf("Find(%q, %q) = %v, want only %s", test.input, test.lang, matches.Diseases, test.want)
+			}
+		})
+	}
+
+	if _, err := namasteRepository.Find(context.Background(), "jvara", "xx"); !errors.Is(err, repository.ErrUnknownLanguage) {
+		t.Errorf("Find in an unknown language returned %v, want ErrUnknownLanguage", err)
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Names of the filters that let ASCII input match transliterated terms.
+const (
+	itransFilterName   = "itrans"
+	foldFilterName     = "fold_transliteration"
+	phoneticFilterName = "phonetic_key"
+)
+
+// itransReplacer rewrites the sequences of the ITRANS-like scheme of the
+// NAMASTE Ayurveda terms that do not fold to their letter. Other capitals,
+// such as the long vowels, are left to folding.
+var itransReplacer = strings.NewReplacer(
+	"Ru", "r",
+	"RRi", "ri",
+	"Sh", "s",
+	"~g", "n",
+	"~j", "n",
+	"~N", "n",
+	"~n", "n",
+	"B", "bh",
+	".h", "",
+	".a", "",
+)
+
+// phoneticReplacer reduces spellings of the same sound to one.
+var phoneticReplacer = strings.NewReplacer(
+	"kh", "k",
+	"gh", "g",
+	"chh", "c",
+	"ch", "c",
+	"jh", "j",
+	"th", "t",
+	"dh", "d",
+	"ph", "p",
+	"bh", "b",
+	"sh", "s",
+	"ee", "i",
+	"oo", "u",
+	"w", "v",
+	"x", "ks",
+	"ri", "r",
+)
+
+type itransFilter struct{}
+
+func (itransFilter) Filter(input []byte) []byte {
+	return []byte(itransReplacer.Replace(string(input)))
+}
+
+type foldFilter struct{}
+
+func (foldFilter) Filter(input []byte) []byte {
+	return []byte(foldTransliteration(string(input)))
+}
+
+type phoneticFilter struct{}
+
+func (phoneticFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		token.Term = []byte(phoneticKey(string(token.Term)))
+	}
+	return input
+}
+
+// foldTransliteration lowercases a transliterated term and strips its
+// diacritics and the ‘ayn and hamza marks of Unani terms.
+func foldTransliteration(term string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(term) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r == '‘' || r == '’' || r == 'ʻ' || r == 'ʼ':
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// phoneticKey reduces a folded word to how it sounds, dropping doubled
+// letters and a final visarga and inherent a.
+func phoneticKey(word string) string {
+	word = phoneticReplacer.Replace(word)
+
+	key := make([]rune, 0, len(word))
+	for _, r := range word {
+		if len(key) > 0 && key[len(key)-1] == r {
+			continue
+		}
+		key = append(key, r)
+	}
+
+	result := strings.TrimSuffix(string(key), "h")
+	if len(result) > 2 {
+		result = strings.TrimSuffix(result, "a")
+	}
+
+	return result
+}
+
+func init() {
+	registry.RegisterCharFilter(itransFilterName, func(map[string]interface{}, *registry.Cache) (analysis.CharFilter, error) {
+		return itransFilter{}, nil
+	})
+	registry.RegisterCharFilter(foldFilterName, func(map[string]interface{}, *registry.Cache) (analysis.CharFilter, error) {
+		return foldFilter{}, nil
+	})
+	registry.RegisterTokenFilter(phoneticFilterName, func(map[string]interface{}, *registry.Cache) (analysis.TokenFilter, error) {
+		return phoneticFilter{}, nil
+	})
+}
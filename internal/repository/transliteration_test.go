@@ -0,0 +1,103 @@
+package repository
+
+import "testing"
+
+func TestFoldTransliteration(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"vyādhi", "vyadhi"},
+		{"vyAdhi", "vyadhi"},
+		{"vyadhi", "vyadhi"},
+		{"jvaraḥ", "jvarah"},
+		{"Su‘āl", "sual"},
+		{"Ḥummā", "humma"},
+	}
+
+	for _, test := range tests {
+		if got := foldTransliteration(test.term); got != test.want {
+			t.Errorf("foldTransliteration(%q) = %q, want %q", test.term, got, test.want)
+		}
+	}
+}
+
+func TestITRANSReplacer(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"jvaraH", "jvaraH"},
+		{"vAtaja", "vAtaja"},
+		{"gRuhya", "grhya"},
+		{"RRitu", "ritu"},
+		{"ShaTka", "saTka"},
+		{"pa~nca", "panca"},
+		{"a~Nga", "anga"},
+		{"Bagandara", "bhagandara"},
+		{"du.hkha", "dukha"},
+		{"a.akAza", "akAza"},
+	}
+
+	for _, test := range tests {
+		if got := itransReplacer.Replace(test.term); got != test.want {
+			t.Errorf("itransReplacer.Replace(%q) = %q, want %q", test.term, got, test.want)
+		}
+	}
+}
+
+func TestPhoneticReplacer(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"kha", "ka"},
+		{"chhardi", "cardi"},
+		{"chakra", "cakra"},
+		{"madhu", "madu"},
+		{"bhaya", "baya"},
+		{"sheet", "sit"},
+		{"soola", "sula"},
+		{"jwara", "jvara"},
+		{"kshaya", "ksaya"},
+		{"xaya", "ksaya"},
+		{"krimi", "krmi"},
+	}
+
+	for _, test := range tests {
+		if got := phoneticReplacer.Replace(test.word); got != test.want {
+			t.Errorf("phoneticReplacer.Replace(%q) = %q, want %q", test.word, got, test.want)
+		}
+	}
+}
+
+func TestPhoneticKey(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"jvara", "jvar"},
+		{"jwara", "jvar"},
+		{"jvarah", "jvar"},
+		{"jvar", "jvar"},
+		{"pitta", "pit"},
+		{"kasah", "kas"},
+		// Words of two letters keep their a.
+		{"ka", "ka"},
+		{"kah", "ka"},
+		{"ha", "ha"},
+	}
+
+	for _, test := range tests {
+		if got := phoneticKey(test.word); got != test.want {
+			t.Errorf("phoneticKey(%q) = %q, want %q", test.word, got, test.want)
+		}
+	}
+
+	// Every spelling of jvara has the same key.
+	for _, term := range []string{"jvaraH", "jvaraḥ", "jwara", "Jvara"} {
+		if got := phoneticKey(foldTransliteration(itransReplacer.Replace(term))); got != "jvar" {
+			t.Errorf("key of %q = %q, want jvar", term, got)
+		}
+	}
+}
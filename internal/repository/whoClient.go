@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the WHO API while it is
+// considered down.
+var ErrCircuitOpen = errors.New("failing repeatedly, not called for now")
+
+// whoMetrics counts the outcomes of the calls to the WHO API. They are
+// served with the other expvar variables.
+var whoMetrics = expvar.NewMap("who_icd_api")
+
+// WHOClientOptions tune how calls to the WHO API survive its failures.
+type WHOClientOptions struct {
+	// Timeout bounds each attempt of a call.
+	Timeout time.Duration
+	// Retries is how many times a call that failed with a network error,
+	// 429 or 5xx is tried again.
+	Retries int
+	// MinBackoff and MaxBackoff bound the jittered wait before a retry. A
+	// Retry-After sent by WHO is waited for instead, up to MaxRetryAfter.
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration
+	// BreakerThreshold is the number of calls in a row that must fail for
+	// the circuit to open. While it is open, calls fail with ErrCircuitOpen
+	// until BreakerCooldown has passed and one trial call succeeds.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultWHOClientOptions are used for the options that are not set.
+var DefaultWHOClientOptions = WHOClientOptions{
+	Timeout:          10 * time.Second,
+	Retries:          2,
+	MinBackoff:       200 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	MaxRetryAfter:    10 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// whoClient sends requests to the WHO API with retries and a circuit
+// breaker.
+type whoClient struct {
+	client  *http.Client
+	options WHOClientOptions
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func newWHOClient(client *http.Client, options WHOClientOptions) *whoClient {
+	defaults := DefaultWHOClientOptions
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.Retries < 0 {
+		options.Retries = 0
+	}
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = defaults.MinBackoff
+	}
+	if options.MaxBackoff < options.MinBackoff {
+		options.MaxBackoff = max(defaults.MaxBackoff, options.MinBackoff)
+	}
+	if options.MaxRetryAfter <= 0 {
+		options.MaxRetryAfter = defaults.MaxRetryAfter
+	}
+	if options.BreakerThreshold <= 0 {
+		options.BreakerThreshold = defaults.BreakerThreshold
+	}
+	if options.BreakerCooldown <= 0 {
+		options.BreakerCooldown = defaults.BreakerCooldown
+	}
+
+	return &whoClient{
+		client:  client,
+		options: options,
+	}
+}
+
+// do sends req, and again after a backoff if it fails in a way that may
+// pass. The response is returned as soon as it is not worth retrying, and
+// the caller must close its body. The body of req must be replayable, which
+// it is for the bodies http.NewRequest knows.
+func (w *whoClient) do(req *http.Request) (*http.Response, error) {
+	if !w.allow() {
+		whoMetrics.Add("rejected", 1)
+		return nil, whoError(ErrCircuitOpen)
+	}
+
+	for attempt := 0; ; attempt++ {
+		whoMetrics.Add("attempts", 1)
+
+		resp, cancel, err := w.attempt(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable {
+			whoMetrics.Add("successes", 1)
+			w.record(true)
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		if err == nil {
+			whoMetrics.Add("status_"+strconv.Itoa(resp.StatusCode), 1)
+		}
+
+		// A request whose caller gave up is not WHO's failure.
+		if req.Context().Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			cancel()
+			whoMetrics.Add("canceled", 1)
+			w.abandon()
+			return nil, whoError(req.Context().Err())
+		}
+
+		if attempt >= w.options.Retries {
+			whoMetrics.Add("failures", 1)
+			w.record(false)
+			if err != nil {
+				cancel()
+				return nil, whoError(err)
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		wait := w.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = min(retryAfter, w.options.MaxRetryAfter)
+			}
+			resp.Body.Close()
+		}
+		cancel()
+
+		whoMetrics.Add("retries", 1)
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			whoMetrics.Add("canceled", 1)
+			w.abandon()
+			return nil, whoError(req.Context().Err())
+		}
+	}
+}
+
+// attempt sends a copy of req within the attempt timeout. The returned
+// cancel releases the timeout once the response has been read.
+func (w *whoClient) attempt(req *http.Request) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), w.options.Timeout)
+
+	attempt := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, func() {}, err
+		}
+		attempt.Body = body
+	}
+
+	resp, err := w.client.Do(attempt)
+	if err != nil {
+		cancel()
+		return nil, func() {}, err
+	}
+
+	return resp, cancel, nil
+}
+
+// backoff returns a random wait before retry number attempt, from the
+// minimum backoff up to twice as long as the previous one could be.
+func (w *whoClient) backoff(attempt int) time.Duration {
+	ceiling := min(w.options.MinBackoff<<attempt, w.options.MaxBackoff)
+	if ceiling <= w.options.MinBackoff {
+		return w.options.MinBackoff
+	}
+
+	return w.options.MinBackoff + rand.N(ceiling-w.options.MinBackoff)
+}
+
+// allow reports whether a call may go out. Once the cooldown of an open
+// circuit has passed, a single trial call is let through.
+func (w *whoClient) allow() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failures < w.options.BreakerThreshold {
+		return true
+	}
+	if w.trial || time.Now().Before(w.openUntil) {
+		return false
+	}
+
+	w.trial = true
+	return true
+}
+
+// record updates the breaker with the outcome of a call.
+func (w *whoClient) record(success bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.trial = false
+	if success {
+		if w.failures >= w.options.BreakerThreshold {
+			whoMetrics.Add("circuit_closes", 1)
+		}
+		w.failures = 0
+		return
+	}
+
+	w.failures++
+	if w.failures >= w.options.BreakerThreshold {
+		if w.failures == w.options.BreakerThreshold {
+			whoMetrics.Add("circuit_opens", 1)
+		}
+		w.openUntil = time.Now().Add(w.options.BreakerCooldown)
+	}
+}
+
+// abandon lets another trial call through after one whose caller gave up,
+// which says nothing about WHO.
+func (w *whoClient) abandon() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.trial = false
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or a date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// cancelBody releases the timeout of an attempt when its response body is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -1,14 +1,17 @@
 package service
 
 import (
+	"backend/cmd/web/dto"
 	"backend/internal/repository"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
-
-	"google.golang.org/genai"
+	"sync"
+	"time"
+	"unicode"
 )
 
 type ICD struct {
@@ -24,103 +27,579 @@ type Namaste struct {
 	Desc string `json:"desc"`
 }
 
+// Disease is a pair of an ICD-11 and a NAMASTE disease. A disease that could
+// not be paired, such as an ICD-11 cluster no NAMASTE disease is mapped to or
+// a match of a source whose counterpart timed out, has only one side and no
+// confidence or method.
 type Disease struct {
-	ICD     ICD     `json:"icd"`
-	Namaste Namaste `json:"namaste"`
+	ICD         ICD     `json:"icd"`
+	Namaste     Namaste `json:"namaste"`
+	Module      string  `json:"module"`
+	Equivalence string  `json:"equivalence"`
+	Status      string  `json:"status"`
+	// Confidence is how sure the method is of the pair, from 0 to 1.
+	Confidence float64 `json:"confidence"`
+	// Method is how the pair was found: MethodLLM, MethodLexical or
+	// MethodCurated.
+	Method string `json:"method"`
+	// Rationale says in a sentence why the pair was made.
+	Rationale string `json:"rationale,omitempty"`
 }
 
+// Methods a pair can be found by.
+const (
+	MethodLLM     = "llm"
+	MethodLexical = "lexical"
+	MethodCurated = "curated"
+)
+
+// ICD-11 modules a NAMASTE disease can be coded in. Dual coding records a
+// disease in both.
+const (
+	ModuleTM2         = "tm2"
+	ModuleBiomedicine = "biomedicine"
+)
+
+var ErrUnknownModule = errors.New("unknown ICD-11 module")
+
 type Matches struct {
 	Diseases []Disease `json:"diseases"`
+	// Warnings explain why the matches may be incomplete, such as a source
+	// that timed out.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type AutoCompleteService interface {
 	Update() error
-	Find(ctx context.Context, input string) (*Matches, error)
+	Find(ctx context.Context, input string, options FindOptions) (*Matches, error)
+}
+
+// FindOptions are the optional inputs of AutoCompleteService.Find.
+type FindOptions struct {
+	// Module restricts the ICD-11 matches to ModuleTM2 or ModuleBiomedicine.
+	// Both are matched when empty.
+	Module string
+	// Language is the language of the input, such as "ta" for Tamil. It is
+	// guessed from the script of the input when empty.
+	Language string
+	// MinConfidence leaves out the pairs found with a lower confidence.
+	// Curated pairs have a confidence of 1.
+	MinConfidence float64
+}
+
+// SourceTimeouts bound how long AutoCompleteService.Find waits for each
+// source before going on without it.
+type SourceTimeouts struct {
+	// ICD bounds the search of each ICD-11 module, including the definitions
+	// fetched for the matches.
+	ICD     time.Duration
+	Namaste time.Duration
+}
+
+// DefaultSourceTimeouts are used for the timeouts that are not configured.
+var DefaultSourceTimeouts = SourceTimeouts{
+	ICD:     15 * time.Second,
+	Namaste: 2 * time.Second,
 }
 
 type autoCompleteService struct {
-	genaiClient       *genai.Client
-	icdRepository     repository.ICDRepository
-	namasteRepository repository.NamasteRepository
-}
-
-const prompt = `
-Here is the ICDRepository response: %s
-Here is the NamasteRepository response: %s
-I want you to carefully match the corresponding diseases from both responses according to the similarity of their descriptions.
-Return the final output strictly in the following JSON format only:
-{
-  "diseases": [
-    {
-      "icd": {
-        "id": "string",
-        "name": "string"
-      },
-      "namaste": {
-        "type": "string",
-        "id": "string",
-        "name": "string",
-      }
-    }
-  ]
-}
-`
+	matcher              Matcher
+	timeouts             SourceTimeouts
+	icdRepository        repository.ICDRepository
+	icdRepositories      map[string]repository.ICDRepository
+	namasteRepository    repository.NamasteRepository
+	conceptMapRepository repository.ConceptMapRepository
+}
 
 // Find implements AutoComplete.
-func (a *autoCompleteService) Find(ctx context.Context, input string) (*Matches, error) {
-	icdMatches, err := a.icdRepository.Find(input)
-	if err != nil {
-		return nil, err
+func (a *autoCompleteService) Find(ctx context.Context, input string, options FindOptions) (*Matches, error) {
+	modules := []string{ModuleBiomedicine, ModuleTM2}
+	if options.Module != "" {
+		if _, ok := a.icdRepositories[options.Module]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownModule, options.Module)
+		}
+		modules = []string{options.Module}
+	}
+
+	if repository.IsICDCluster(input) {
+		return a.findCluster(ctx, input, modules, options)
+	}
+
+	// ICD-11 has no terms in the native scripts, so a native input is looked
+	// up in ICD-11 by the transliterated term of its best NAMASTE match, once
+	// that is known. Otherwise both are searched at the same time.
+	var (
+		wg          sync.WaitGroup
+		icdMatches  map[string]*repository.ICDMatches
+		icdAnswered bool
+		icdWarnings []string
+		icdErr      error
+	)
+	latin := latinScript(input)
+	if latin {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			icdMatches, icdAnswered, icdWarnings, icdErr = a.findICD(ctx, input, modules)
+		}()
 	}
 
-	namasteMatches, err := a.namasteRepository.Find(input)
+	namasteMatches, warnings, err := a.findNamaste(ctx, input, options.Language)
 	if err != nil {
+		wg.Wait()
 		return nil, err
 	}
 
+	if !latin {
+		icdInput := input
+		if namasteMatches != nil && len(namasteMatches.Diseases) > 0 {
+			icdInput = namasteMatches.Diseases[0].Name
+		}
+		icdMatches, icdAnswered, icdWarnings, icdErr = a.findICD(ctx, icdInput, modules)
+	}
+
+	wg.Wait()
+	if icdErr != nil {
+		return nil, icdErr
+	}
+	warnings = append(warnings, icdWarnings...)
+
 	log.Println("icdMatches:")
 	log.Println(icdMatches)
 	log.Print("namasteMatches:")
 	log.Println(namasteMatches)
 
-	genaiResponse, err := a.genaiClient.Models.GenerateContent(ctx, "gemini-2.5-flash", genai.Text(fmt.Sprintf(prompt, icdMatches, namasteMatches)), nil)
+	// Without NAMASTE there is nothing to pair, so the ICD-11 matches are
+	// returned on their own.
+	if namasteMatches == nil {
+		return &Matches{Diseases: unpairedICD(icdMatches, modules), Warnings: warnings}, nil
+	}
+
+	// Reviewed mappings take precedence, so the model is only asked about
+	// the NAMASTE matches that lack an accepted mapping in some module.
+	reviewed, err := a.curatedDiseases(namasteMatches.Diseases, modules)
 	if err != nil {
 		return nil, err
 	}
 
-	resultLines := strings.Split(genaiResponse.Text(), "\n")
-
-	fmt.Println(genaiResponse.Text())
+	if len(reviewed.pending) == 0 {
+		return &Matches{Diseases: reviewed.curated, Warnings: warnings}, nil
+	}
+	if !icdAnswered {
+		return &Matches{Diseases: append(reviewed.curated, unpairedNamaste(reviewed.pending)...), Warnings: warnings}, nil
+	}
 
-	result := ""
+	matches, source, err := a.match(ctx, icdMatches, reviewed.pending)
+	if err != nil {
+		return nil, err
+	}
 
-	for i, line := range resultLines {
-		if i == 0 || i == len(resultLines)-1 {
+	suggested := make([]Disease, 0, len(matches))
+	for _, disease := range matches {
+		disease.Module = icdModule(disease.ICD.ID)
+		if !slices.Contains(modules, disease.Module) || reviewed.rejected[pairKey(disease)] || reviewed.covered[moduleKey(disease)] {
 			continue
 		}
+		disease.Status = repository.StatusProposed
+		suggested = append(suggested, disease)
+	}
+
+	// Every suggestion is kept for review, however weak.
+	a.saveMappings(suggested, source)
+
+	suggested = slices.DeleteFunc(suggested, func(disease Disease) bool {
+		return disease.Confidence < options.MinConfidence
+	})
+
+	return &Matches{Diseases: append(reviewed.curated, suggested...), Warnings: warnings}, nil
+}
+
+// findNamaste searches NAMASTE within its timeout. If it times out, the
+// matches are nil and a warning says so.
+func (a *autoCompleteService) findNamaste(ctx context.Context, input string, lang string) (*repository.NamasteMatches, []string, error) {
+	namasteCtx, cancel := context.WithTimeout(ctx, a.timeouts.Namaste)
+	defer cancel()
+
+	matches, err := a.namasteRepository.Find(namasteCtx, input, lang)
+	if timedOut(ctx, err) {
+		return nil, []string{"NAMASTE search timed out, so no NAMASTE codes could be matched"}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return matches, nil, nil
+}
+
+// findICD searches the ICD-11 modules in parallel, within the ICD-11
+// timeout. A module that times out or whose API fails has no matches and a
+// warning says so. It reports whether any module answered.
+func (a *autoCompleteService) findICD(ctx context.Context, input string, modules []string) (map[string]*repository.ICDMatches, bool, []string, error) {
+	icdCtx, cancel := context.WithTimeout(ctx, a.timeouts.ICD)
+	defer cancel()
+
+	results := make([]*repository.ICDMatches, len(modules))
+	errs := make([]error, len(modules))
+
+	var wg sync.WaitGroup
+	for i, module := range modules {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = a.icdRepositories[module].Find(icdCtx, input)
+		}()
+	}
+	wg.Wait()
+
+	icdMatches := map[string]*repository.ICDMatches{
+		ModuleBiomedicine: {},
+		ModuleTM2:         {},
+	}
+	answered := false
+	var warnings []string
+	for i, module := range modules {
+		switch {
+		case timedOut(ctx, errs[i]):
+			warnings = append(warnings, "ICD-11 "+module+" search timed out, so no "+module+" codes could be matched")
+		case upstreamFailed(ctx, errs[i]):
+			log.Println("Error: " + errs[i].Error())
+			warnings = append(warnings, "ICD-11 "+module+" search failed, so no "+module+" codes could be matched")
+		case errs[i] != nil:
+			return nil, false, nil, errs[i]
+		default:
+			answered = true
+			icdMatches[module] = results[i]
+			if undefined := results[i].Undefined; len(undefined) > 0 {
+				warnings = append(warnings, "ICD-11 definitions of "+strings.Join(undefined, ", ")+" could not be fetched, so those codes were matched by title alone")
+			}
+		}
+	}
+
+	return icdMatches, answered, warnings, nil
+}
+
+// unpairedICD returns the ICD-11 matches of modules as unpaired diseases.
+func unpairedICD(icdMatches map[string]*repository.ICDMatches, modules []string) []Disease {
+	diseases := make([]Disease, 0)
+	for _, module := range modules {
+		for _, match := range icdMatches[module].Matches {
+			diseases = append(diseases, Disease{
+				ICD:    ICD{ID: match.ID, Name: match.Name, Desc: match.Desc},
+				Module: module,
+			})
+		}
+	}
+
+	return diseases
+}
+
+// unpairedNamaste returns NAMASTE matches as unpaired diseases.
+func unpairedNamaste(matches []repository.NamasteMatch) []Disease {
+	diseases := make([]Disease, 0, len(matches))
+	for _, match := range matches {
+		diseases = append(diseases, Disease{
+			Namaste: Namaste{Type: match.Type, ID: match.ID, Name: match.Name, Desc: match.Desc},
+		})
+	}
+
+	return diseases
+}
+
+// upstreamFailed reports whether err is a failure of a remote API, such as
+// the WHO API being down, rather than of this server or the request.
+func upstreamFailed(ctx context.Context, err error) bool {
+	var upstreamErr *repository.UpstreamError
+	return errors.As(err, &upstreamErr) && ctx.Err() == nil
+}
+
+// timedOut reports whether err is the timeout of a source rather than ctx,
+// the request itself, being done.
+func timedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+// match pairs the pending NAMASTE matches with the ICD-11 matches, falling
+// back to the lexical matcher, and returns the name of the matcher used.
+func (a *autoCompleteService) match(ctx context.Context, icdMatches map[string]*repository.ICDMatches, pending []repository.NamasteMatch) ([]Disease, string, error) {
+	diseases, err := a.matcher.Match(ctx, icdMatches, pending)
+	if err == nil {
+		return diseases, a.matcher.Name(), nil
+	}
+
+	if _, ok := a.matcher.(*lexicalMatcher); ok {
+		return nil, "", err
+	}
+
+	log.Println("Error: " + err.Error() + ", falling back to lexical matching")
+
+	fallback := NewLexicalMatcher()
+	diseases, err = fallback.Match(ctx, icdMatches, pending)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return diseases, fallback.Name(), nil
+}
+
+// latinScript reports whether every letter of input is in the Latin script.
+func latinScript(input string) bool {
+	for _, r := range input {
+		if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// review is what terminologists already decided about the NAMASTE matches of
+// a search.
+type review struct {
+	// curated holds the accepted mappings.
+	curated []Disease
+	// rejected holds the pairKey of the rejected pairs.
+	rejected map[string]bool
+	// covered holds the moduleKey of the NAMASTE diseases that have an
+	// accepted mapping in a module.
+	covered map[string]bool
+	// pending holds the matches that still need the model.
+	pending []repository.NamasteMatch
+}
 
-		result += line + "\n"
+// curatedDiseases reviews the NAMASTE matches against the concept map for
+// the given modules.
+func (a *autoCompleteService) curatedDiseases(namasteMatches []repository.NamasteMatch, modules []string) (*review, error) {
+	result := &review{
+		curated:  make([]Disease, 0),
+		rejected: make(map[string]bool),
+		covered:  make(map[string]bool),
+		pending:  make([]repository.NamasteMatch, 0),
 	}
 
-	fmt.Println(result)
+	for _, match := range namasteMatches {
+		mappings, err := a.conceptMapRepository.FindByNamaste(match.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mapping := range mappings {
+			disease := diseaseFromMapping(mapping)
+			if mapping.NamasteType != match.Type || !slices.Contains(modules, disease.Module) {
+				continue
+			}
 
-	var matches Matches
-	if err := json.Unmarshal([]byte(result), &matches); err != nil {
-		return nil, fmt.Errorf("unable to decode genai response: %w", err)
+			switch mapping.Status {
+			case repository.StatusAccepted:
+				disease.Namaste.Desc = match.Desc
+				curate(&disease, mapping)
+				result.curated = append(result.curated, disease)
+				result.covered[moduleKey(disease)] = true
+			case repository.StatusRejected:
+				result.rejected[pairKey(disease)] = true
+			}
+		}
+
+		for _, module := range modules {
+			key := moduleKey(Disease{Namaste: Namaste{Type: match.Type, ID: match.ID}, Module: module})
+			if !result.covered[key] {
+				result.pending = append(result.pending, match)
+				break
+			}
+		}
 	}
 
-	return &matches, nil
+	return result, nil
+}
+
+// findCluster returns the NAMASTE diseases a postcoordinated code is mapped
+// to, or the cluster alone if it is not mapped.
+func (a *autoCompleteService) findCluster(ctx context.Context, input string, modules []string, options FindOptions) (*Matches, error) {
+	cluster, err := repository.ParseICDCluster(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// A cluster belongs to the module of its first stem code, though its
+	// other stem codes may be from either module, as in a dual coding.
+	module := icdModule(cluster.Stems[0].Code)
+	if !slices.Contains(modules, module) {
+		return &Matches{Diseases: make([]Disease, 0)}, nil
+	}
+
+	icdCtx, cancel := context.WithTimeout(ctx, a.timeouts.ICD)
+	defer cancel()
+
+	match, unknown, err := resolveCluster(icdCtx, a.icdRepository, a.icdRepository, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if unknown != "" {
+		return nil, fmt.Errorf("%w: unknown code %s", repository.ErrInvalidCluster, unknown)
+	}
+
+	mappings, err := a.conceptMapRepository.FindByICD(match.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	diseases := make([]Disease, 0)
+	for _, mapping := range preferAccepted(mappings) {
+		disease := diseaseFromMapping(mapping)
+		disease.ICD = ICD{ID: match.ID, Name: match.Name, Desc: match.Desc}
+		if mapping.Status == repository.StatusAccepted {
+			curate(&disease, mapping)
+		}
+		if disease.Confidence >= options.MinConfidence {
+			diseases = append(diseases, disease)
+		}
+	}
+
+	if len(mappings) == 0 {
+		diseases = append(diseases, Disease{
+			ICD:    ICD{ID: match.ID, Name: match.Name, Desc: match.Desc},
+			Module: module,
+		})
+	}
+
+	return &Matches{Diseases: diseases}, nil
+}
+
+// curate gives disease, found by an accepted mapping, the confidence and the
+// rationale of a curated pair.
+func curate(disease *Disease, mapping repository.Mapping) {
+	disease.Confidence = 1
+	if mapping.Comment != "" {
+		disease.Rationale = mapping.Comment
+	} else {
+		disease.Rationale = "Accepted by " + reviewerName(mapping.Reviewer)
+	}
+}
+
+// icdModule returns the module of an ICD-11 code. Module I codes are not
+// TM2.
+func icdModule(code string) string {
+	if repository.IsTM2(code) {
+		return ModuleTM2
+	}
+
+	return ModuleBiomedicine
+}
+
+func diseaseFromMapping(mapping repository.Mapping) Disease {
+	return Disease{
+		ICD: ICD{
+			ID:   mapping.ICDCode,
+			Name: mapping.ICDName,
+		},
+		Namaste: Namaste{
+			Type: mapping.NamasteType,
+			ID:   mapping.NamasteCode,
+			Name: mapping.NamasteName,
+		},
+		Module:      icdModule(mapping.ICDCode),
+		Equivalence: mapping.Equivalence,
+		Status:      mapping.Status,
+		Confidence:  mapping.Confidence,
+		Method:      mappingMethod(mapping),
+		Rationale:   mapping.Rationale,
+	}
+}
+
+// PairingExtensions returns the extensions carrying the confidence, the
+// method and the rationale of the pair of disease.
+func PairingExtensions(disease Disease) []dto.Extension {
+	confidence := disease.Confidence
+	extensions := []dto.Extension{
+		{URL: ConfidenceExtension, ValueDecimal: &confidence},
+		{URL: MethodExtension, ValueCode: disease.Method},
+	}
+	if disease.Rationale != "" {
+		extensions = append(extensions, dto.Extension{URL: RationaleExtension, ValueString: disease.Rationale})
+	}
+
+	return extensions
+}
+
+// mappingMethod returns the method of a stored mapping. Whatever proposed a
+// mapping, once accepted it is curated.
+func mappingMethod(mapping repository.Mapping) string {
+	switch {
+	case mapping.Status == repository.StatusAccepted:
+		return MethodCurated
+	case mapping.Source == MethodLexical:
+		return MethodLexical
+	default:
+		return MethodLLM
+	}
+}
+
+// reviewerName names the reviewer of a mapping in a rationale.
+func reviewerName(reviewer string) string {
+	if reviewer == "" {
+		return "a terminologist"
+	}
+
+	return reviewer
+}
+
+func pairKey(disease Disease) string {
+	return disease.Namaste.Type + "/" + disease.Namaste.ID + "|" + disease.ICD.ID
+}
+
+func moduleKey(disease Disease) string {
+	return disease.Namaste.Type + "/" + disease.Namaste.ID + "|" + disease.Module
+}
+
+// saveMappings persists the pairs found by the model in the concept map so
+// they can be translated later without asking the model again. Failing to
+// store them does not fail the search.
+func (a *autoCompleteService) saveMappings(diseases []Disease, source string) {
+	mappings := make([]repository.Mapping, 0, len(diseases))
+	for i := range diseases {
+		disease := &diseases[i]
+		if !validEquivalence(disease.Equivalence) {
+			disease.Equivalence = "relatedto"
+		}
+
+		mappings = append(mappings, repository.Mapping{
+			NamasteType: disease.Namaste.Type,
+			NamasteCode: disease.Namaste.ID,
+			NamasteName: disease.Namaste.Name,
+			ICDCode:     disease.ICD.ID,
+			ICDName:     disease.ICD.Name,
+			Equivalence: disease.Equivalence,
+			Source:      source,
+			Confidence:  disease.Confidence,
+			Rationale:   disease.Rationale,
+		})
+	}
+
+	if err := a.conceptMapRepository.Propose(mappings); err != nil {
+		log.Println("Error: unable to save mappings: " + err.Error())
+	}
 }
 
 func (a *autoCompleteService) Update() error {
-	a.namasteRepository.CreateIndex("index.bleve")
-	return nil
+	return a.namasteRepository.CreateIndex()
 }
 
-func NewAutoComplete(genaiClient *genai.Client, icdRepository repository.ICDRepository, namasteRepository repository.NamasteRepository) AutoCompleteService {
+func NewAutoComplete(matcher Matcher, timeouts SourceTimeouts, icdRepository repository.ICDRepository, namasteRepository repository.NamasteRepository, conceptMapRepository repository.ConceptMapRepository) AutoCompleteService {
+	if timeouts.ICD <= 0 {
+		timeouts.ICD = DefaultSourceTimeouts.ICD
+	}
+	if timeouts.Namaste <= 0 {
+		timeouts.Namaste = DefaultSourceTimeouts.Namaste
+	}
+
 	return &autoCompleteService{
-		genaiClient:       genaiClient,
-		icdRepository:     icdRepository,
-		namasteRepository: namasteRepository,
+		matcher:       matcher,
+		timeouts:      timeouts,
+		icdRepository: icdRepository,
+		icdRepositories: map[string]repository.ICDRepository{
+			ModuleBiomedicine: icdRepository.Chapters(repository.BiomedicineChapters),
+			ModuleTM2:         icdRepository.Chapters(repository.TM2Chapters),
+		},
+		namasteRepository:    namasteRepository,
+		conceptMapRepository: conceptMapRepository,
 	}
 }
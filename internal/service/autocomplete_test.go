@@ -0,0 +1,377 @@
+package service_test
+
+import (
+	"backend/internal/repository"
+	"backend/internal/service"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNamasteRepository finds the same matches whatever the input, after
+// delay unless ctx is done first.
+type fakeNamasteRepository struct {
+	matches []repository.NamasteMatch
+	delay   time.Duration
+}
+
+func (f *fakeNamasteRepository) CreateIndex() error {
+	return nil
+}
+
+func (f *fakeNamasteRepository) Find(ctx context.Context, input string, lang string) (*repository.NamasteMatches, error) {
+	if err := sleep(ctx, f.delay); err != nil {
+		return nil, err
+	}
+	return &repository.NamasteMatches{Diseases: f.matches}, nil
+}
+
+func (f *fakeNamasteRepository) List(ctx context.Context, size int) ([]repository.NamasteMatch, error) {
+	return f.matches, nil
+}
+
+func (f *fakeNamasteRepository) Lookup(ctx context.Context, code string) ([]repository.Record, error) {
+	return nil, nil
+}
+
+func (f *fakeNamasteRepository) Search(ctx context.Context, input string, branch string, offset int, size int) ([]repository.Record, uint64, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeNamasteRepository) Suggest(ctx context.Context, input string, lang string, branch string, size int) ([]repository.Record, error) {
+	return nil, nil
+}
+
+// slowICDRepository finds ICD-11 matches after delay, unless ctx is done
+// first.
+type slowICDRepository struct {
+	repository.ICDRepository
+	delay time.Duration
+}
+
+func (s *slowICDRepository) Find(ctx context.Context, input string) (*repository.ICDMatches, error) {
+	if err := sleep(ctx, s.delay); err != nil {
+		return nil, err
+	}
+	return s.ICDRepository.Find(ctx, input)
+}
+
+func (s *slowICDRepository) Chapters(chapters []string) repository.ICDRepository {
+	return &slowICDRepository{ICDRepository: s.ICDRepository.Chapters(chapters), delay: s.delay}
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jvara is the NAMASTE disease the autocomplete tests find, which pairs with
+// SK60 and MG26 of the "fever" search of icdapitest.
+var jvara = repository.NamasteMatch{Type: "ayurveda", ID: "AAA-1", Name: "jvaraH", ShortDesc: "Fever", Desc: "Elevated body temperature"}
+
+// newAutoComplete returns an autocomplete service pairing with provider, on
+// the ICD-11 of icdapitest and an empty concept map, which it also returns.
+func newAutoComplete(t *testing.T, provider service.LLMProvider) (service.AutoCompleteService, repository.ConceptMapRepository) {
+	t.Helper()
+	return newSlowAutoComplete(t, provider, 0, 0)
+}
+
+// newSlowAutoComplete is newAutoComplete with sources that take icdDelay and
+// namasteDelay to answer, and time out after 100ms.
+func newSlowAutoComplete(t *testing.T, provider service.LLMProvider, icdDelay time.Duration, namasteDelay time.Duration) (service.AutoCompleteService, repository.ConceptMapRepository) {
+	t.Helper()
+
+	conceptMapRepository, err := repository.NewConceptMapRepository(filepath.Join(t.TempDir(), "conceptmap.db"))
+	if err != nil {
+		t.Fatalf("NewConceptMapRepository: %v", err)
+	}
+
+	timeouts := service.SourceTimeouts{}
+	if icdDelay > 0 || namasteDelay > 0 {
+		timeouts = service.SourceTimeouts{ICD: 100 * time.Millisecond, Namaste: 100 * time.Millisecond}
+	}
+
+	namasteRepository := &fakeNamasteRepository{matches: []repository.NamasteMatch{jvara}, delay: namasteDelay}
+	slowRepository := &slowICDRepository{ICDRepository: newICDRepository(t), delay: icdDelay}
+	return service.NewAutoComplete(service.NewModelMatcher(provider), timeouts, slowRepository, namasteRepository, conceptMapRepository), conceptMapRepository
+}
+
+// jvaraResponse pairs jvara with SK60 and MG26, with these confidences.
+func jvaraResponse(sk60 string, mg26 string) string {
+	return `{"diseases":[` +
+		`{"icd":{"id":"SK60"},"namaste":{"type":"ayurveda","id":"AAA-1"},"equivalence":"equivalent","confidence":` + sk60 + `,"rationale":"Both are fevers."},` +
+		`{"icd":{"id":"MG26"},"namaste":{"type":"ayurveda","id":"AAA-1"},"equivalence":"wider","confidence":` + mg26 + `,"rationale":"A fever of unknown origin."}]}`
+}
+
+func icdIDs(diseases []service.Disease) []string {
+	ids := make([]string, 0, len(diseases))
+	for _, disease := range diseases {
+		ids = append(ids, disease.ICD.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestAutoCompleteFindPairsWithModel(t *testing.T) {
+	provider := newScriptedProvider(jvaraResponse("0.9", "0.6"))
+	autoComplete, conceptMapRepository := newAutoComplete(t, provider)
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	if ids := icdIDs(matches.Diseases); !slices.Equal(ids, []string{"MG26", "SK60"}) {
+		t.Fatalf("Find paired %v, want MG26 and SK60", ids)
+	}
+	for _, disease := range matches.Diseases {
+		module := service.ModuleTM2
+		if disease.ICD.ID == "MG26" {
+			module = service.ModuleBiomedicine
+		}
+		if disease.Module != module || disease.Status != repository.StatusProposed || disease.Method != service.MethodLLM || disease.Namaste.ID != jvara.ID {
+			t.Errorf("Find returned %+v", disease)
+		}
+	}
+
+	// The model is given the candidates of both modules.
+	prompts := provider.Prompts()
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "SK60") || !strings.Contains(prompts[0], "MG26") || !strings.Contains(prompts[0], jvara.ID) {
+		t.Errorf("model asked %q", prompts)
+	}
+
+	// The suggestions are kept for review.
+	mappings, err := conceptMapRepository.FindByNamaste(jvara.ID)
+	if err != nil {
+		t.Fatalf("FindByNamaste: %v", err)
+	}
+	if len(mappings) != 2 || mappings[0].Status != repository.StatusProposed || mappings[0].Source != provider.Model() {
+		t.Errorf("stored %+v, want 2 proposed mappings", mappings)
+	}
+}
+
+func TestAutoCompleteFindMinConfidence(t *testing.T) {
+	autoComplete, conceptMapRepository := newAutoComplete(t, newScriptedProvider(jvaraResponse("0.9", "0.3")))
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{MinConfidence: 0.5})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if ids := icdIDs(matches.Diseases); !slices.Equal(ids, []string{"SK60"}) {
+		t.Errorf("Find returned %v, want only SK60", ids)
+	}
+
+	// Weak suggestions are kept for review all the same.
+	mappings, err := conceptMapRepository.FindByNamaste(jvara.ID)
+	if err != nil {
+		t.Fatalf("FindByNamaste: %v", err)
+	}
+	if len(mappings) != 2 {
+		t.Errorf("stored %d mappings, want 2", len(mappings))
+	}
+}
+
+func TestAutoCompleteFindModule(t *testing.T) {
+	autoComplete, _ := newAutoComplete(t, newScriptedProvider(jvaraResponse("0.9", "0.6")))
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{Module: service.ModuleTM2})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if ids := icdIDs(matches.Diseases); !slices.Equal(ids, []string{"SK60"}) {
+		t.Errorf("Find in TM2 returned %v, want only SK60", ids)
+	}
+
+	if _, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{Module: "unani"}); !errors.Is(err, service.ErrUnknownModule) {
+		t.Errorf("Find in an unknown module returned %v, want ErrUnknownModule", err)
+	}
+}
+
+func TestAutoCompleteFindCurated(t *testing.T) {
+	provider := newScriptedProvider()
+	autoComplete, conceptMapRepository := newAutoComplete(t, provider)
+
+	err := conceptMapRepository.Propose([]repository.Mapping{
+		{NamasteType: jvara.Type, NamasteCode: jvara.ID, ICDCode: "SK60", Equivalence: "equivalent"},
+		{NamasteType: jvara.Type, NamasteCode: jvara.ID, ICDCode: "MG26", Equivalence: "wider"},
+	})
+	if err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+	mappings, err := conceptMapRepository.FindByNamaste(jvara.ID)
+	if err != nil {
+		t.Fatalf("FindByNamaste: %v", err)
+	}
+	for _, mapping := range mappings {
+		mapping.Status = repository.StatusAccepted
+		mapping.Reviewer = "asha"
+		if err := conceptMapRepository.Update(mapping); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	if ids := icdIDs(matches.Diseases); !slices.Equal(ids, []string{"MG26", "SK60"}) {
+		t.Fatalf("Find returned %v, want the curated MG26 and SK60", ids)
+	}
+	for _, disease := range matches.Diseases {
+		if disease.Method != service.MethodCurated || disease.Confidence != 1 || disease.Rationale != "Accepted by asha" {
+			t.Errorf("Find returned %+v", disease)
+		}
+	}
+
+	// Both modules are covered, so the model is not asked.
+	if n := len(provider.Prompts()); n != 0 {
+		t.Errorf("model asked %d times", n)
+	}
+}
+
+func TestAutoCompleteFindFallsBackToLexical(t *testing.T) {
+	provider := newScriptedProvider("no JSON here", "still none")
+	autoComplete, conceptMapRepository := newAutoComplete(t, provider)
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	if len(matches.Diseases) == 0 {
+		t.Fatal("Find returned nothing")
+	}
+	for _, disease := range matches.Diseases {
+		if disease.Method != service.MethodLexical {
+			t.Errorf("Find returned %+v, want lexical pairs", disease)
+		}
+	}
+	if n := len(provider.Prompts()); n != 2 {
+		t.Errorf("model asked %d times, want 2", n)
+	}
+
+	mappings, err := conceptMapRepository.FindByNamaste(jvara.ID)
+	if err != nil {
+		t.Fatalf("FindByNamaste: %v", err)
+	}
+	for _, mapping := range mappings {
+		if mapping.Source != service.MethodLexical {
+			t.Errorf("stored %+v, want it found lexically", mapping)
+		}
+	}
+}
+
+func TestAutoCompleteFindCluster(t *testing.T) {
+	provider := newScriptedProvider()
+	autoComplete, conceptMapRepository := newAutoComplete(t, provider)
+
+	matches, err := autoComplete.Find(context.Background(), "sk60 / sa81", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches.Diseases) != 1 {
+		t.Fatalf("Find returned %v, want the cluster alone", matches.Diseases)
+	}
+	disease := matches.Diseases[0]
+	if disease.ICD.ID != "SK60/SA81" || disease.ICD.Name != "Fever disorder (TM2) / Liver system disorders (TM1)" || disease.Module != service.ModuleTM2 || disease.Namaste.ID != "" {
+		t.Errorf("Find returned %+v", disease)
+	}
+
+	err = conceptMapRepository.Propose([]repository.Mapping{
+		{NamasteType: jvara.Type, NamasteCode: jvara.ID, NamasteName: jvara.Name, ICDCode: "SK60/SA81", Equivalence: "equivalent", Confidence: 0.8},
+	})
+	if err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+
+	matches, err = autoComplete.Find(context.Background(), "SK60/SA81", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches.Diseases) != 1 || matches.Diseases[0].Namaste.ID != jvara.ID || matches.Diseases[0].ICD.Name == "" {
+		t.Errorf("Find returned %v, want the mapped jvaraH", matches.Diseases)
+	}
+
+	if _, err := autoComplete.Find(context.Background(), "SK60/SA99", service.FindOptions{}); !errors.Is(err, repository.ErrInvalidCluster) {
+		t.Errorf("Find of a cluster of an unknown code returned %v, want ErrInvalidCluster", err)
+	}
+
+	// Text is searched even if it looks like a cluster.
+	if _, err := autoComplete.Find(context.Background(), "cough & cold", service.FindOptions{}); err != nil {
+		t.Errorf("Find of text with & returned %v", err)
+	}
+
+	if n := len(provider.Prompts()); n != 0 {
+		t.Errorf("model asked %d times", n)
+	}
+}
+
+func TestAutoCompleteFindNamasteTimesOut(t *testing.T) {
+	provider := newScriptedProvider()
+	autoComplete, _ := newSlowAutoComplete(t, provider, 0, time.Second)
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	// The ICD-11 matches are returned unpaired.
+	if ids := icdIDs(matches.Diseases); !slices.Equal(ids, []string{"MG26", "SK60"}) {
+		t.Errorf("Find returned %v, want MG26 and SK60", ids)
+	}
+	for _, disease := range matches.Diseases {
+		if disease.Namaste.ID != "" || disease.Method != "" || disease.Module == "" {
+			t.Errorf("Find returned %+v, want an unpaired ICD-11 code", disease)
+		}
+	}
+	if len(matches.Warnings) != 1 || !strings.Contains(matches.Warnings[0], "NAMASTE") {
+		t.Errorf("Warnings = %q, want the NAMASTE timeout", matches.Warnings)
+	}
+	if n := len(provider.Prompts()); n != 0 {
+		t.Errorf("model asked %d times", n)
+	}
+}
+
+func TestAutoCompleteFindICDTimesOut(t *testing.T) {
+	provider := newScriptedProvider()
+	autoComplete, _ := newSlowAutoComplete(t, provider, time.Second, 0)
+
+	matches, err := autoComplete.Find(context.Background(), "fever", service.FindOptions{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	// The NAMASTE matches are returned unpaired.
+	if len(matches.Diseases) != 1 {
+		t.Fatalf("Find returned %v, want jvaraH alone", matches.Diseases)
+	}
+	if disease := matches.Diseases[0]; disease.Namaste.ID != jvara.ID || disease.ICD.ID != "" || disease.Method != "" {
+		t.Errorf("Find returned %+v, want an unpaired jvaraH", disease)
+	}
+	if len(matches.Warnings) != 2 {
+		t.Errorf("Warnings = %q, want a timeout of each module", matches.Warnings)
+	}
+	if n := len(provider.Prompts()); n != 0 {
+		t.Errorf("model asked %d times", n)
+	}
+}
+
+func TestAutoCompleteFindCanceled(t *testing.T) {
+	autoComplete, _ := newSlowAutoComplete(t, newScriptedProvider(), time.Second, time.Second)
+
+	// A request given up on is not a source timing out.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := autoComplete.Find(ctx, "fever", service.FindOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Find of a canceled request returned %v, want context.Canceled", err)
+	}
+}
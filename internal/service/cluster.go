@@ -0,0 +1,49 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"strings"
+)
+
+// resolveCluster looks up the stem codes of cluster in stems and its
+// extension codes in extensions. It returns the cluster named by the titles
+// of its codes, or else the first code that does not exist.
+func resolveCluster(ctx context.Context, stems repository.ICDRepository, extensions repository.ICDRepository, cluster repository.ICDCluster) (*repository.ICDMatch, string, error) {
+	names := make([]string, 0, len(cluster.Stems))
+	var desc string
+
+	for _, stem := range cluster.Stems {
+		match, err := stems.Get(ctx, stem.Code)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, stem.Code, nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if desc == "" {
+			desc = match.Desc
+		}
+
+		parts := []string{match.Name}
+		for _, code := range stem.Extensions {
+			extension, err := extensions.Get(ctx, code)
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, code, nil
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			parts = append(parts, extension.Name)
+		}
+
+		names = append(names, strings.Join(parts, " & "))
+	}
+
+	return &repository.ICDMatch{
+		ID:   cluster.String(),
+		Name: strings.Join(names, " / "),
+		Desc: desc,
+	}, "", nil
+}
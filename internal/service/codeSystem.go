@@ -3,11 +3,29 @@ package service
 import (
 	"backend/cmd/web/dto"
 	"backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
 )
 
+// VersionOptions are the version and displayLanguage inputs of the
+// terminology operations. Only ICD-11 has more than one version and
+// language, so they are ignored for NAMASTE. Empty fields select the
+// configured release and language.
+type VersionOptions struct {
+	Version         string
+	DisplayLanguage string
+}
+
 type CodeSystemService interface {
-	ListNamaste(size int, url string) (*dto.CodeSystem, error)
-	ListICD(size int, url string) (*dto.CodeSystem, error)
+	ListNamaste(ctx context.Context, size int, url string) (*dto.CodeSystem, error)
+	ListICD(ctx context.Context, size int, url string, options VersionOptions) (*dto.CodeSystem, error)
+	ListTM2(ctx context.Context, size int, url string, options VersionOptions) (*dto.CodeSystem, error)
+	Lookup(ctx context.Context, system string, code string, options VersionOptions) (*dto.Parameters, error)
+	ValidateCode(ctx context.Context, system string, code string, display string, options VersionOptions) (*dto.Parameters, error)
 }
 
 type codeSystemService struct {
@@ -16,8 +34,27 @@ type codeSystemService struct {
 }
 
 // ListICD implements CodeSystemService.
-func (c *codeSystemService) ListICD(size int, url string) (*dto.CodeSystem, error) {
-	list, err := c.icdRepository.List(size)
+func (c *codeSystemService) ListICD(ctx context.Context, size int, url string, options VersionOptions) (*dto.CodeSystem, error) {
+	return listICD(ctx, c.icdRepository, "ICD", "ICD Codes", size, url, options)
+}
+
+// ListTM2 implements CodeSystemService.
+func (c *codeSystemService) ListTM2(ctx context.Context, size int, url string, options VersionOptions) (*dto.CodeSystem, error) {
+	return listICD(ctx, c.icdRepository.Chapters(repository.TM2Chapters), "ICD-TM2", "ICD-11 TM2 Codes", size, url, options)
+}
+
+// listICD lists the codes of icdRepository as a code system. One more code
+// than asked for is fetched to tell whether the list is the whole code
+// system.
+func listICD(ctx context.Context, icdRepository repository.ICDRepository, id string, name string, size int, url string, options VersionOptions) (*dto.CodeSystem, error) {
+	size = max(size, 0)
+
+	icdRepository, err := withVersion(ctx, icdRepository, options)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := icdRepository.List(ctx, size+1)
 	if err != nil {
 		return nil, err
 	}
@@ -25,16 +62,22 @@ func (c *codeSystemService) ListICD(size int, url string) (*dto.CodeSystem, erro
 	var result dto.CodeSystem
 
 	result.ResourceType = "CodeSystem"
-	result.Version = "1.0"
+	result.Version = icdRepository.Edition().Release
+	result.Language = icdRepository.Edition().Language
 	result.Status = "active"
 	result.Content = "complete"
-	result.ID = "ICD"
-	result.Name = "ICD Codes"
+	result.ID = id
+	result.Name = name
 	result.URL = url
 	result.Concept = make([]dto.Concept, 0)
 
+	if len(list) > size {
+		result.Content = "fragment"
+		list = list[:size]
+	}
+
 	for _, match := range list {
-		result.Concept = append(result.Concept, dto.Concept{
+		concept := dto.Concept{
 			Code:       match.ID,
 			Display:    match.Name,
 			Definition: match.Desc,
@@ -44,15 +87,35 @@ func (c *codeSystemService) ListICD(size int, url string) (*dto.CodeSystem, erro
 					ValueString: "ICD",
 				},
 			},
-		})
+		}
+
+		if match.Kind != "" {
+			concept.Property = append(concept.Property, dto.Property{Code: "kind", ValueCode: match.Kind})
+		}
+
+		// Chapters and blocks group codes but cannot be used as codes
+		if match.Kind == "chapter" || match.Kind == "block" {
+			notSelectable := true
+			concept.Property = append(concept.Property, dto.Property{Code: "notSelectable", ValueBoolean: &notSelectable})
+		}
+
+		if match.Parent != "" {
+			concept.Property = append(concept.Property, dto.Property{Code: "parent", ValueCode: match.Parent})
+		}
+
+		for _, child := range match.Children {
+			concept.Property = append(concept.Property, dto.Property{Code: "child", ValueCode: child})
+		}
+
+		result.Concept = append(result.Concept, concept)
 	}
 
 	return &result, nil
 }
 
 // ListNamaste implements CodeSystemService.
-func (c *codeSystemService) ListNamaste(size int, url string) (*dto.CodeSystem, error) {
-	list, err := c.namasteRepository.List(size)
+func (c *codeSystemService) ListNamaste(ctx context.Context, size int, url string) (*dto.CodeSystem, error) {
+	list, err := c.namasteRepository.List(ctx, size)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +123,7 @@ func (c *codeSystemService) ListNamaste(size int, url string) (*dto.CodeSystem,
 	var result dto.CodeSystem
 
 	result.ResourceType = "CodeSystem"
-	result.Version = "1.0"
+	result.Version = namasteVersion
 	result.Status = "active"
 	result.Content = "complete"
 	result.ID = "NAMASTE"
@@ -85,6 +148,274 @@ func (c *codeSystemService) ListNamaste(size int, url string) (*dto.CodeSystem,
 	return &result, nil
 }
 
+// Lookup implements CodeSystemService.
+func (c *codeSystemService) Lookup(ctx context.Context, system string, code string, options VersionOptions) (*dto.Parameters, error) {
+	if chapters, ok := icdChapters(system); ok {
+		return c.lookupICD(ctx, chapters, code, options)
+	}
+
+	branch, ok := namasteBranch(system)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSystem, system)
+	}
+
+	return c.lookupNamaste(ctx, branch, code)
+}
+
+// lookupICD looks code up in the edition asked for. When a display language
+// is asked for, the display is repeated as a designation in the language it
+// is actually in, which is English where WHO has no translation.
+func (c *codeSystemService) lookupICD(ctx context.Context, chapters []string, code string, options VersionOptions) (*dto.Parameters, error) {
+	icdRepository, err := withVersion(ctx, c.icdRepository.Chapters(chapters), options)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := icdRepository.Get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []dto.Parameter{
+		{Name: "name", ValueString: "ICD Codes"},
+		{Name: "version", ValueString: icdRepository.Edition().Release},
+		{Name: "display", ValueString: match.Name},
+	}
+
+	if match.Desc != "" {
+		parameters = append(parameters, dto.Parameter{Name: "definition", ValueString: match.Desc})
+	}
+
+	if options.DisplayLanguage != "" {
+		parameters = append(parameters, dto.Parameter{
+			Name: "designation",
+			Part: []dto.Parameter{
+				{Name: "language", ValueCode: icdRepository.Edition().Language},
+				{Name: "value", ValueString: match.Name},
+			},
+		})
+	}
+
+	return &dto.Parameters{
+		ResourceType: "Parameters",
+		Parameter:    parameters,
+	}, nil
+}
+
+func (c *codeSystemService) lookupNamaste(ctx context.Context, branch string, code string) (*dto.Parameters, error) {
+	found, err := c.findNamaste(ctx, branch, code)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(found) == 0:
+		return nil, repository.ErrNotFound
+	case len(found) > 1:
+		return nil, fmt.Errorf("%w: %s", ErrAmbiguousCode, code)
+	}
+
+	record := found[0]
+
+	parameters := []dto.Parameter{
+		{Name: "name", ValueString: "NAMASTE Codes"},
+		{Name: "version", ValueString: namasteVersion},
+		{Name: "display", ValueString: record.Diacritical},
+	}
+
+	if record.LongDesc != "" {
+		parameters = append(parameters, dto.Parameter{Name: "definition", ValueString: record.LongDesc})
+	}
+
+	if record.Native != "" {
+		parameters = append(parameters, dto.Parameter{
+			Name: "designation",
+			Part: []dto.Parameter{
+				{Name: "language", ValueCode: nativeLanguages[record.Type]},
+				{Name: "value", ValueString: record.Native},
+			},
+		})
+	}
+
+	parameters = append(parameters, dto.Parameter{
+		Name: "property",
+		Part: []dto.Parameter{
+			{Name: "code", ValueCode: "type"},
+			{Name: "value", ValueString: record.Type},
+		},
+	})
+
+	if record.ShortDesc != "" {
+		parameters = append(parameters, dto.Parameter{
+			Name: "property",
+			Part: []dto.Parameter{
+				{Name: "code", ValueCode: "shortDefinition"},
+				{Name: "value", ValueString: record.ShortDesc},
+			},
+		})
+	}
+
+	return &dto.Parameters{
+		ResourceType: "Parameters",
+		Parameter:    parameters,
+	}, nil
+}
+
+// findNamaste returns the records for code, restricted to branch unless
+// branch is empty.
+func (c *codeSystemService) findNamaste(ctx context.Context, branch string, code string) ([]repository.Record, error) {
+	records, err := c.namasteRepository.Lookup(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []repository.Record
+	for _, record := range records {
+		if branch == "" || record.Type == branch {
+			found = append(found, record)
+		}
+	}
+
+	return found, nil
+}
+
+// ValidateCode implements CodeSystemService. An unknown code or a display
+// that does not belong to the code is a negative result, not an error.
+func (c *codeSystemService) ValidateCode(ctx context.Context, system string, code string, display string, options VersionOptions) (*dto.Parameters, error) {
+	if chapters, ok := icdChapters(system); ok {
+		return c.validateICD(ctx, system, chapters, code, display, options)
+	}
+
+	branch, ok := namasteBranch(system)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSystem, system)
+	}
+
+	return c.validateNamaste(ctx, system, branch, code, display)
+}
+
+func (c *codeSystemService) validateICD(ctx context.Context, system string, chapters []string, code string, display string, options VersionOptions) (*dto.Parameters, error) {
+	icdRepository, err := withVersion(ctx, c.icdRepository.Chapters(chapters), options)
+	if err != nil {
+		return nil, err
+	}
+
+	if repository.IsICDCluster(code) {
+		return c.validateICDCluster(ctx, system, icdRepository, code, display, options)
+	}
+
+	match, err := icdRepository.Get(ctx, code)
+	if errors.Is(err, repository.ErrNotFound) {
+		return validationResult(false, fmt.Sprintf("Unknown code %s in %s", code, system), ""), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if display != "" && !sameTerm(display, match.Name) {
+		return validationResult(false, fmt.Sprintf("Display %q is not valid for code %s; expected %q", display, code, match.Name), match.Name), nil
+	}
+
+	return validationResult(true, "", match.Name), nil
+}
+
+// validateICDCluster validates a postcoordinated code, whose stem codes must
+// be in stems, the repository of system. Its extension codes come from
+// chapter X, which is in every ICD-11 system.
+func (c *codeSystemService) validateICDCluster(ctx context.Context, system string, stems repository.ICDRepository, code string, display string, options VersionOptions) (*dto.Parameters, error) {
+	cluster, err := repository.ParseICDCluster(code)
+	if err != nil {
+		return validationResult(false, err.Error(), ""), nil
+	}
+
+	extensions, err := withVersion(ctx, c.icdRepository, options)
+	if err != nil {
+		return nil, err
+	}
+
+	match, unknown, err := resolveCluster(ctx, stems, extensions, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if unknown != "" {
+		return validationResult(false, fmt.Sprintf("Unknown code %s in cluster %s of %s", unknown, code, system), ""), nil
+	}
+
+	if display != "" && !sameTerm(display, match.Name) {
+		return validationResult(false, fmt.Sprintf("Display %q is not valid for cluster %s; expected %q", display, code, match.Name), match.Name), nil
+	}
+
+	return validationResult(true, "", match.Name), nil
+}
+
+func (c *codeSystemService) validateNamaste(ctx context.Context, system string, branch string, code string, display string) (*dto.Parameters, error) {
+	records, err := c.findNamaste(ctx, branch, code)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return validationResult(false, fmt.Sprintf("Unknown code %s in %s", code, system), ""), nil
+	}
+
+	if display == "" {
+		if len(records) > 1 {
+			return validationResult(true, fmt.Sprintf("Code %s exists in more than one NAMASTE branch; use a branch system to get its display", code), ""), nil
+		}
+		return validationResult(true, "", records[0].Diacritical), nil
+	}
+
+	// Any of the terms of a record is an acceptable display for its code.
+	for _, record := range records {
+		for _, term := range []string{record.Diacritical, record.Term, record.Native} {
+			if term != "" && sameTerm(display, term) {
+				return validationResult(true, "", record.Diacritical), nil
+			}
+		}
+	}
+
+	expected := ""
+	if len(records) == 1 {
+		expected = records[0].Diacritical
+	}
+
+	return validationResult(false, fmt.Sprintf("Display %q is not valid for code %s", display, code), expected), nil
+}
+
+// withVersion returns a view of icdRepository serving the release and
+// language asked for by options.
+func withVersion(ctx context.Context, icdRepository repository.ICDRepository, options VersionOptions) (repository.ICDRepository, error) {
+	return icdRepository.WithEdition(ctx, repository.ICDEdition{
+		Release:  options.Version,
+		Language: options.DisplayLanguage,
+	})
+}
+
+// sameTerm compares two terms ignoring case and Unicode composition, as the
+// NAMASTE files store diacritics decomposed.
+func sameTerm(a string, b string) bool {
+	return strings.EqualFold(norm.NFC.String(a), norm.NFC.String(b))
+}
+
+func validationResult(result bool, message string, display string) *dto.Parameters {
+	parameters := []dto.Parameter{
+		{Name: "result", ValueBoolean: &result},
+	}
+
+	if message != "" {
+		parameters = append(parameters, dto.Parameter{Name: "message", ValueString: message})
+	}
+
+	if display != "" {
+		parameters = append(parameters, dto.Parameter{Name: "display", ValueString: display})
+	}
+
+	return &dto.Parameters{
+		ResourceType: "Parameters",
+		Parameter:    parameters,
+	}
+}
+
 func NewCodeSystemService(namasteRepository repository.NamasteRepository, icdRepository repository.ICDRepository) CodeSystemService {
 	return &codeSystemService{
 		namasteRepository: namasteRepository,
@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/service"
+	"context"
+	"strings"
+	"testing"
+)
+
+func newCodeSystemService(t *testing.T) service.CodeSystemService {
+	t.Helper()
+	return service.NewCodeSystemService(newNamasteRepository(t), newICDRepository(t))
+}
+
+// parameter returns the value of the first parameter called name, and of
+// its part called part if part is not empty.
+func parameter(parameters *dto.Parameters, name string, part string) string {
+	for _, p := range parameters.Parameter {
+		if p.Name != name {
+			continue
+		}
+		if part == "" {
+			return p.ValueString
+		}
+		for _, q := range p.Part {
+			if q.Name == part {
+				return q.ValueString + q.ValueCode
+			}
+		}
+	}
+	return ""
+}
+
+func validationOutcome(parameters *dto.Parameters) bool {
+	for _, p := range parameters.Parameter {
+		if p.Name == "result" && p.ValueBoolean != nil {
+			return *p.ValueBoolean
+		}
+	}
+	return false
+}
+
+func TestCodeSystemLookup(t *testing.T) {
+	codeSystemService := newCodeSystemService(t)
+
+	tests := []struct {
+		name     string
+		system   string
+		code     string
+		options  service.VersionOptions
+		display  string
+		language string
+	}{
+		{"ICD", service.ICDSystem, "1A00", service.VersionOptions{}, "Cholera", ""},
+		{"ICD in Spanish", service.ICDSystem, "1A00", service.VersionOptions{DisplayLanguage: "es"}, "Cólera", "es"},
+		// WHO has not translated the release into Hindi.
+		{"ICD in Hindi", service.ICDSystem, "1A00", service.VersionOptions{DisplayLanguage: "hi"}, "Cholera", "en"},
+		{"TM2", service.ICDTM2System, "SK60", service.VersionOptions{}, "Fever disorder (TM2)", ""},
+		{"NAMASTE branch", service.NamasteSystem + "/ayurveda", "AAA-1", service.VersionOptions{}, "jvaraḥ", "sa"},
+		{"NAMASTE code of one branch", service.NamasteSystem, "SB-2", service.VersionOptions{}, "Kasarogam", "ta"},
+		{"NAMASTE code with spaces", service.NamasteSystem + "/unani", " UA-1 ", service.VersionOptions{}, "Su‘āl", "ur"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parameters, err := codeSystemService.Lookup(context.Background(), test.system, test.code, test.options)
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+			if display := parameter(parameters, "display", ""); display != test.display {
+				t.Errorf("display = %q, want %q", display, test.display)
+			}
+			if language := parameter(parameters, "designation", "language"); language != test.language {
+				t.Errorf("designation language = %q, want %q", language, test.language)
+			}
+		})
+	}
+}
+
+func TestCodeSystemLookupErrors(t *testing.T) {
+	codeSystemService := newCodeSystemService(t)
+
+	tests := []struct {
+		name    string
+		system  string
+		code    string
+		options service.VersionOptions
+		kind    service.ErrorKind
+	}{
+		{"unknown ICD code", service.ICDSystem, "1A09", service.VersionOptions{}, service.KindNotFound},
+		{"ICD code outside TM2", service.ICDTM2System, "1A00", service.VersionOptions{}, service.KindNotFound},
+		{"Module I code in TM2", service.ICDTM2System, "SA80", service.VersionOptions{}, service.KindNotFound},
+		{"unknown release", service.ICDSystem, "1A00", service.VersionOptions{Version: "1999-01"}, service.KindInvalid},
+		{"unknown NAMASTE code", service.NamasteSystem, "ZZZ-9", service.VersionOptions{}, service.KindNotFound},
+		{"NAMASTE code of another branch", service.NamasteSystem + "/unani", "AAA-1", service.VersionOptions{}, service.KindNotFound},
+		{"NAMASTE code of two branches", service.NamasteSystem, "AAA-1", service.VersionOptions{}, service.KindInvalid},
+		{"unknown system", "http://snomed.info/sct", "22298006", service.VersionOptions{}, service.KindInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := codeSystemService.Lookup(context.Background(), test.system, test.code, test.options); err == nil || service.KindOf(err) != test.kind {
+				t.Errorf("Lookup returned %v, want kind %d", err, test.kind)
+			}
+		})
+	}
+}
+
+func TestCodeSystemValidateCode(t *testing.T) {
+	codeSystemService := newCodeSystemService(t)
+
+	tests := []struct {
+		name    string
+		system  string
+		code    string
+		display string
+		options service.VersionOptions
+		result  bool
+		// expected is the display returned, and message a part of the
+		// message.
+		expected string
+		message  string
+	}{
+		{"ICD code", service.ICDSystem, "1A00", "", service.VersionOptions{}, true, "Cholera", ""},
+		{"ICD display in another case", service.ICDSystem, "1A00", "CHOLERA", service.VersionOptions{}, true, "Cholera", ""},
+		{"ICD display decomposed", service.ICDSystem, "1A00", "CO\u0301LERA", service.VersionOptions{DisplayLanguage: "es"}, true, "Cólera", ""},
+		{"ICD wrong display", service.ICDSystem, "1A00", "Typhoid fever", service.VersionOptions{}, false, "Cholera", "is not valid"},
+		{"ICD unknown code", service.ICDSystem, "1A09", "", service.VersionOptions{}, false, "", "Unknown code 1A09"},
+		{"Module I code in TM2", service.ICDTM2System, "SA80", "", service.VersionOptions{}, false, "", "Unknown code SA80"},
+		{"cluster", service.ICDSystem, "1a00/1a01", "", service.VersionOptions{}, true, "Cholera / Intestinal infection due to other Vibrio", ""},
+		{"cluster display", service.ICDSystem, "1A00/1A01", "cholera / intestinal infection due to other vibrio", service.VersionOptions{}, true, "Cholera / Intestinal infection due to other Vibrio", ""},
+		{"cluster wrong display", service.ICDSystem, "1A00/1A01", "Cholera", service.VersionOptions{}, false, "Cholera / Intestinal infection due to other Vibrio", "is not valid for cluster"},
+		{"cluster unknown stem", service.ICDSystem, "1A00/1A09", "", service.VersionOptions{}, false, "", "Unknown code 1A09 in cluster"},
+		{"cluster unknown extension", service.ICDSystem, "1A00&XS25", "", service.VersionOptions{}, false, "", "Unknown code XS25 in cluster"},
+		{"cluster stem outside TM2", service.ICDTM2System, "SK60/1A00", "", service.VersionOptions{}, false, "", "Unknown code 1A00 in cluster"},
+		{"malformed cluster", service.ICDSystem, "XS25&1A00", "", service.VersionOptions{}, false, "", "must follow a stem code"},
+		{"NAMASTE code", service.NamasteSystem + "/ayurveda", "AAA-1", "", service.VersionOptions{}, true, "jvaraḥ", ""},
+		{"NAMASTE display decomposed", service.NamasteSystem + "/ayurveda", "AAA-1", "jvarah\u0323", service.VersionOptions{}, true, "jvaraḥ", ""},
+		{"NAMASTE term", service.NamasteSystem + "/ayurveda", "AAA-1", "JVARAH", service.VersionOptions{}, true, "jvaraḥ", ""},
+		{"NAMASTE native term", service.NamasteSystem + "/ayurveda", "AAA-1", "ज्वरः", service.VersionOptions{}, true, "jvaraḥ", ""},
+		{"NAMASTE term of another branch", service.NamasteSystem + "/ayurveda", "AAA-1", "Suram", service.VersionOptions{}, false, "jvaraḥ", "is not valid"},
+		{"NAMASTE code of two branches", service.NamasteSystem, "AAA-1", "", service.VersionOptions{}, true, "", "more than one NAMASTE branch"},
+		{"NAMASTE display picks the branch", service.NamasteSystem, "AAA-1", "suram", service.VersionOptions{}, true, "Suram", ""},
+		{"NAMASTE wrong display of two branches", service.NamasteSystem, "AAA-1", "Fever", service.VersionOptions{}, false, "", "is not valid"},
+		{"NAMASTE unknown code", service.NamasteSystem, "ZZZ-9", "", service.VersionOptions{}, false, "", "Unknown code ZZZ-9"},
+		{"NAMASTE code of another branch", service.NamasteSystem + "/unani", "AAA-1", "", service.VersionOptions{}, false, "", "Unknown code AAA-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parameters, err := codeSystemService.ValidateCode(context.Background(), test.system, test.code, test.display, test.options)
+			if err != nil {
+				t.Fatalf("ValidateCode: %v", err)
+			}
+			if result := validationOutcome(parameters); result != test.result {
+				t.Errorf("result = %t, want %t", result, test.result)
+			}
+			if display := parameter(parameters, "display", ""); display != test.expected {
+				t.Errorf("display = %q, want %q", display, test.expected)
+			}
+			if message := parameter(parameters, "message", ""); !strings.Contains(message, test.message) || (test.message == "") != (message == "") {
+				t.Errorf("message = %q, want it to hold %q", message, test.message)
+			}
+		})
+	}
+
+	if _, err := codeSystemService.ValidateCode(context.Background(), "http://snomed.info/sct", "22298006", "", service.VersionOptions{}); service.KindOf(err) != service.KindInvalid {
+		t.Errorf("ValidateCode of an unknown system returned %v, want an invalid request", err)
+	}
+}
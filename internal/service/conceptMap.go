@@ -0,0 +1,229 @@
+package service
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/repository"
+	"fmt"
+	"slices"
+)
+
+type ConceptMapService interface {
+	Get() (*dto.ConceptMap, error)
+	Translate(system string, code string, target string) (*dto.Parameters, error)
+}
+
+type conceptMapService struct {
+	conceptMapRepository repository.ConceptMapRepository
+}
+
+// reverseEquivalence gives the equivalence of a mapping read from the ICD
+// side, where wider and narrower swap places.
+var reverseEquivalence = map[string]string{
+	"relatedto":   "relatedto",
+	"equivalent":  "equivalent",
+	"equal":       "equal",
+	"wider":       "narrower",
+	"subsumes":    "specializes",
+	"narrower":    "wider",
+	"specializes": "subsumes",
+	"inexact":     "inexact",
+	"unmatched":   "unmatched",
+	"disjoint":    "disjoint",
+}
+
+func validEquivalence(equivalence string) bool {
+	_, ok := reverseEquivalence[equivalence]
+	return ok
+}
+
+// Get implements ConceptMapService. Mappings are grouped by NAMASTE branch,
+// since codes are only unique within a branch.
+func (c *conceptMapService) Get() (*dto.ConceptMap, error) {
+	mappings, err := c.conceptMapRepository.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var result dto.ConceptMap
+
+	result.ResourceType = "ConceptMap"
+	result.ID = "namaste-icd"
+	result.URL = ConceptMapURL
+	result.Version = "1.0"
+	result.Name = "NAMASTE to ICD-11"
+	result.Status = "active"
+	result.SourceURI = NamasteSystem + "?fhir_vs"
+	result.TargetURI = ICDSystem + "?fhir_vs"
+	result.Group = make([]dto.Group, 0)
+
+	groups := make(map[string]int)
+	elements := make(map[string]int)
+	for _, mapping := range mappings {
+		if mappingStatus(mapping) == repository.StatusRejected {
+			continue
+		}
+
+		// TM2 and biomedicine targets go to separate groups, so each side of
+		// a dual coding can be told apart by its target system.
+		source := NamasteSystem + "/" + mapping.NamasteType
+		target := icdSystem(mapping.ICDCode)
+
+		g, ok := groups[source+"|"+target]
+		if !ok {
+			g = len(result.Group)
+			groups[source+"|"+target] = g
+			result.Group = append(result.Group, dto.Group{
+				Source: source,
+				Target: target,
+			})
+		}
+		group := &result.Group[g]
+
+		key := source + "|" + target + "|" + mapping.NamasteCode
+		e, ok := elements[key]
+		if !ok {
+			e = len(group.Element)
+			elements[key] = e
+			group.Element = append(group.Element, dto.Element{
+				Code:    mapping.NamasteCode,
+				Display: mapping.NamasteName,
+			})
+		}
+
+		// Targets are rated the way autocomplete rates the same pairs.
+		disease := diseaseFromMapping(mapping)
+		if mapping.Status == repository.StatusAccepted {
+			curate(&disease, mapping)
+		}
+
+		group.Element[e].Target = append(group.Element[e].Target, dto.Target{
+			Code:        mapping.ICDCode,
+			Display:     mapping.ICDName,
+			Equivalence: mapping.Equivalence,
+			Comment:     mapping.Comment,
+			Extension:   PairingExtensions(disease),
+		})
+	}
+
+	return &result, nil
+}
+
+// Translate implements ConceptMapService. An empty target is the other code
+// system, and ICDTM2System only returns TM2 codes. Accepted mappings hide
+// proposed ones, and rejected mappings are never used.
+func (c *conceptMapService) Translate(system string, code string, target string) (*dto.Parameters, error) {
+	var matches []dto.Parameter
+
+	switch {
+	case isNamasteSystem(system):
+		chapters, ok := icdChapters(target)
+		if target != "" && !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSystem, target)
+		}
+
+		branch, _ := namasteBranch(system)
+		mappings, err := c.conceptMapRepository.FindByNamaste(code)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mapping := range preferAcceptedByModule(inBranch(mappings, branch)) {
+			if len(chapters) > 0 && !slices.Contains(chapters, repository.ICDChapter(mapping.ICDCode)) {
+				continue
+			}
+
+			matches = append(matches, translationMatch(mapping.Equivalence, dto.Coding{
+				System:  icdSystem(mapping.ICDCode),
+				Code:    mapping.ICDCode,
+				Display: mapping.ICDName,
+			}))
+		}
+	case isICDSystem(system):
+		branch, ok := "", true
+		if target != "" {
+			branch, ok = namasteBranch(target)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSystem, target)
+		}
+
+		mappings, err := c.conceptMapRepository.FindByICD(code)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mapping := range preferAccepted(inBranch(mappings, branch)) {
+			matches = append(matches, translationMatch(reverseEquivalence[mapping.Equivalence], dto.Coding{
+				System:  NamasteSystem + "/" + mapping.NamasteType,
+				Code:    mapping.NamasteCode,
+				Display: mapping.NamasteName,
+			}))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSystem, system)
+	}
+
+	result := len(matches) > 0
+	parameters := []dto.Parameter{
+		{Name: "result", ValueBoolean: &result},
+	}
+
+	if !result {
+		parameters = append(parameters, dto.Parameter{Name: "message", ValueString: fmt.Sprintf("No mapping found for code %s in %s", code, system)})
+	}
+
+	return &dto.Parameters{
+		ResourceType: "Parameters",
+		Parameter:    append(parameters, matches...),
+	}, nil
+}
+
+// preferAcceptedByModule applies preferAccepted to the TM2 and the
+// biomedicine mappings separately, so that a NAMASTE code keeps a translation
+// for each side of a dual coding.
+func preferAcceptedByModule(mappings []repository.Mapping) []repository.Mapping {
+	var tm2, biomedicine []repository.Mapping
+	for _, mapping := range mappings {
+		if icdSystem(mapping.ICDCode) == ICDTM2System {
+			tm2 = append(tm2, mapping)
+		} else {
+			biomedicine = append(biomedicine, mapping)
+		}
+	}
+
+	return append(preferAccepted(tm2), preferAccepted(biomedicine)...)
+}
+
+// inBranch keeps the mappings of a NAMASTE branch, or all of them if branch
+// is empty.
+func inBranch(mappings []repository.Mapping, branch string) []repository.Mapping {
+	if branch == "" {
+		return mappings
+	}
+
+	var result []repository.Mapping
+	for _, mapping := range mappings {
+		if mapping.NamasteType == branch {
+			result = append(result, mapping)
+		}
+	}
+
+	return result
+}
+
+func translationMatch(equivalence string, concept dto.Coding) dto.Parameter {
+	return dto.Parameter{
+		Name: "match",
+		Part: []dto.Parameter{
+			{Name: "equivalence", ValueCode: equivalence},
+			{Name: "concept", ValueCoding: &concept},
+			{Name: "source", ValueURI: ConceptMapURL},
+		},
+	}
+}
+
+func NewConceptMapService(conceptMapRepository repository.ConceptMapRepository) ConceptMapService {
+	return &conceptMapService{
+		conceptMapRepository: conceptMapRepository,
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"errors"
+)
+
+// ErrorKind classifies service errors by who is at fault, which decides how
+// they are reported to clients.
+type ErrorKind int
+
+const (
+	// KindInternal is a failure of this server.
+	KindInternal ErrorKind = iota
+	// KindInvalid is a request that cannot be served as asked.
+	KindInvalid
+	// KindNotFound is a request for a code or resource that does not exist.
+	KindNotFound
+	// KindUpstream is a failure of a remote API, such as WHO's ICD API.
+	KindUpstream
+	// KindUnavailable is a dependency that cannot be reached at all, such as
+	// the language model.
+	KindUnavailable
+	// KindConflict is a change that clashes with the stored state, such as
+	// editing a mapping into the pair of another one.
+	KindConflict
+)
+
+// Error is an error with an explicit kind.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of an error returned by a service.
+func KindOf(err error) ErrorKind {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Kind
+	}
+
+	var upstreamErr *repository.UpstreamError
+	switch {
+	case errors.Is(err, repository.ErrCircuitOpen):
+		return KindUnavailable
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrMappingNotFound):
+		return KindNotFound
+	case errors.Is(err, repository.ErrMappingConflict):
+		return KindConflict
+	case errors.Is(err, ErrUnknownSystem), errors.Is(err, ErrAmbiguousCode),
+		errors.Is(err, ErrUnknownValueSet), errors.Is(err, ErrExpansionTooLarge), errors.Is(err, ErrInvalidMapping),
+		errors.Is(err, ErrUnknownModule), errors.Is(err, repository.ErrUnknownLanguage),
+		errors.Is(err, repository.ErrInvalidEdition), errors.Is(err, repository.ErrInvalidCluster):
+		return KindInvalid
+	case errors.As(err, &upstreamErr):
+		return KindUpstream
+	default:
+		return KindInternal
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// lexicalMatcher pairs matches by the TF-IDF cosine similarity of their terms
+// and definitions, without calling a language model.
+type lexicalMatcher struct{}
+
+// minLexicalScore is the similarity below which a pair is not suggested.
+const minLexicalScore = 0.15
+
+// stopWords are left out of the similarity as they carry no meaning.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "due": true, "for": true, "from": true, "in": true,
+	"into": true, "is": true, "it": true, "its": true, "of": true, "on": true,
+	"or": true, "other": true, "that": true, "the": true, "this": true, "to": true,
+	"which": true, "with": true, "without": true, "unspecified": true,
+}
+
+// Name implements Matcher.
+func (l *lexicalMatcher) Name() string {
+	return "lexical"
+}
+
+// Match implements Matcher. Every NAMASTE match is paired with the most
+// similar ICD-11 match of each module, if it is similar enough, and the pairs
+// are returned best first.
+func (l *lexicalMatcher) Match(_ context.Context, icdMatches map[string]*repository.ICDMatches, namasteMatches []repository.NamasteMatch) ([]Disease, error) {
+	// The terms of the NAMASTE matches count twice: they are short and say
+	// the most, while definitions are long and wordy.
+	var documents [][]string
+	namasteTerms := make([][]string, len(namasteMatches))
+	for i, match := range namasteMatches {
+		namasteTerms[i] = terms(match.Name, match.Name, match.ShortDesc, match.ShortDesc, match.Desc)
+		documents = append(documents, namasteTerms[i])
+	}
+
+	icdTerms := make(map[string][][]string)
+	for module, matches := range icdMatches {
+		if matches == nil {
+			continue
+		}
+		for _, match := range matches.Matches {
+			t := terms(match.Name, match.Name, match.Desc)
+			icdTerms[module] = append(icdTerms[module], t)
+			documents = append(documents, t)
+		}
+	}
+
+	idf := inverseDocumentFrequencies(documents)
+
+	diseases := make([]Disease, 0)
+	for i, namaste := range namasteMatches {
+		namasteVector := tfidf(namasteTerms[i], idf)
+
+		for _, module := range []string{ModuleBiomedicine, ModuleTM2} {
+			var bestVector map[string]float64
+			best, bestScore := -1, minLexicalScore
+			for j := range icdTerms[module] {
+				icdVector := tfidf(icdTerms[module][j], idf)
+				score := cosine(namasteVector, icdVector)
+				if score >= bestScore {
+					best, bestScore, bestVector = j, score, icdVector
+				}
+			}
+			if best < 0 {
+				continue
+			}
+
+			icd := icdMatches[module].Matches[best]
+			diseases = append(diseases, Disease{
+				ICD: ICD{
+					ID:   icd.ID,
+					Name: icd.Name,
+					Desc: icd.Desc,
+				},
+				Namaste: Namaste{
+					Type: namaste.Type,
+					ID:   namaste.ID,
+					Name: namaste.Name,
+					Desc: namaste.Desc,
+				},
+				Module:      module,
+				Equivalence: lexicalEquivalence(namaste, icd),
+				Confidence:  math.Round(bestScore*1000) / 1000,
+				Method:      MethodLexical,
+				Rationale:   "Shares the words " + strings.Join(sharedTerms(namasteVector, bestVector, 3), ", "),
+			})
+		}
+	}
+
+	slices.SortStableFunc(diseases, func(a, b Disease) int {
+		switch {
+		case a.Confidence > b.Confidence:
+			return -1
+		case a.Confidence < b.Confidence:
+			return 1
+		}
+		return 0
+	})
+
+	return diseases, nil
+}
+
+// lexicalEquivalence is "equivalent" when the ICD-11 name is the English
+// gloss of the NAMASTE term. Words alone cannot tell a broader concept from
+// a narrower one, so every other pair is only related.
+func lexicalEquivalence(namaste repository.NamasteMatch, icd repository.ICDMatch) string {
+	if namaste.ShortDesc != "" && slices.Equal(terms(namaste.ShortDesc), terms(icd.Name)) {
+		return "equivalent"
+	}
+
+	return "relatedto"
+}
+
+// terms splits texts into lower case words without diacritics or stop words,
+// with a plural s stripped.
+func terms(texts ...string) []string {
+	var result []string
+	for _, text := range texts {
+		words := strings.FieldsFunc(norm.NFD.String(strings.ToLower(text)), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+		})
+
+		for _, word := range words {
+			word = strings.Map(func(r rune) rune {
+				if unicode.Is(unicode.Mn, r) {
+					return -1
+				}
+				return r
+			}, word)
+
+			if len(word) < 2 || stopWords[word] {
+				continue
+			}
+			if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+				word = strings.TrimSuffix(word, "s")
+			}
+
+			result = append(result, word)
+		}
+	}
+
+	return result
+}
+
+func inverseDocumentFrequencies(documents [][]string) map[string]float64 {
+	frequencies := make(map[string]int)
+	for _, document := range documents {
+		seen := make(map[string]bool)
+		for _, term := range document {
+			if !seen[term] {
+				seen[term] = true
+				frequencies[term]++
+			}
+		}
+	}
+
+	idf := make(map[string]float64, len(frequencies))
+	for term, frequency := range frequencies {
+		idf[term] = math.Log(1 + float64(len(documents))/float64(frequency))
+	}
+
+	return idf
+}
+
+// tfidf weighs each term of a document by the log of its count and its
+// inverse document frequency.
+func tfidf(document []string, idf map[string]float64) map[string]float64 {
+	counts := make(map[string]int)
+	for _, term := range document {
+		counts[term]++
+	}
+
+	vector := make(map[string]float64, len(counts))
+	for term, count := range counts {
+		vector[term] = (1 + math.Log(float64(count))) * idf[term]
+	}
+
+	return vector
+}
+
+func cosine(a map[string]float64, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, weight := range a {
+		dot += weight * b[term]
+		normA += weight * weight
+	}
+	for _, weight := range b {
+		normB += weight * weight
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / math.Sqrt(normA*normB)
+}
+
+// sharedTerms returns up to n of the terms of both vectors that add the most
+// to their similarity.
+func sharedTerms(a map[string]float64, b map[string]float64, n int) []string {
+	shared := make([]string, 0)
+	for term := range a {
+		if b[term] > 0 {
+			shared = append(shared, term)
+		}
+	}
+
+	slices.SortFunc(shared, func(x, y string) int {
+		wx, wy := a[x]*b[x], a[y]*b[y]
+		switch {
+		case wx > wy:
+			return -1
+		case wx < wy:
+			return 1
+		}
+		return strings.Compare(x, y)
+	})
+
+	return shared[:min(n, len(shared))]
+}
+
+func NewLexicalMatcher() Matcher {
+	return &lexicalMatcher{}
+}
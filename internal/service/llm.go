@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// LLMProvider generates text from a prompt with a language model.
+type LLMProvider interface {
+	// Model is the name of the model the provider generates with.
+	Model() string
+	// Generate answers prompt. If schema is not nil, the answer is a JSON
+	// value of that JSON schema, enforced by the structured output mode of
+	// the model where it has one.
+	Generate(ctx context.Context, prompt string, schema map[string]any) (string, error)
+}
+
+// DefaultGeminiModel is the Gemini model used when none is configured.
+const DefaultGeminiModel = "gemini-2.5-flash"
+
+// geminiProvider generates with Google's Gemini API.
+type geminiProvider struct {
+	genaiClient *genai.Client
+	model       string
+}
+
+// Model implements LLMProvider.
+func (g *geminiProvider) Model() string {
+	return g.model
+}
+
+// Generate implements LLMProvider.
+func (g *geminiProvider) Generate(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+	var config *genai.GenerateContentConfig
+	if schema != nil {
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType:   "application/json",
+			ResponseJsonSchema: schema,
+		}
+	}
+
+	response, err := g.genaiClient.Models.GenerateContent(ctx, g.model, genai.Text(prompt), config)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate with %s: %w", g.model, err)
+	}
+
+	return response.Text(), nil
+}
+
+func NewGeminiProvider(genaiClient *genai.Client, model string) LLMProvider {
+	return &geminiProvider{
+		genaiClient: genaiClient,
+		model:       model,
+	}
+}
+
+// openAIProvider generates with an OpenAI compatible chat completions API.
+type openAIProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIJSONSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Model implements LLMProvider.
+func (o *openAIProvider) Model() string {
+	return o.model
+}
+
+// Generate implements LLMProvider.
+func (o *openAIProvider) Generate(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+	request := openAIRequest{
+		Model:    o.model,
+		Messages: []openAIMessage{{Role: "user", Content: prompt}},
+	}
+	if schema != nil {
+		request.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: "response", Strict: true, Schema: schema},
+		}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate with %s: %w", o.model, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("unable to generate with %s: status %d: %s", o.model, res.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var response openAIResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("unable to decode response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no completion from %s", o.model)
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// NewOpenAIProvider returns a provider for the API at baseURL, such as
+// "https://api.openai.com/v1". apiKey may be empty.
+func NewOpenAIProvider(client *http.Client, baseURL string, apiKey string, model string) LLMProvider {
+	return &openAIProvider{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// errScriptExhausted is returned by a scripted provider that has no
+// responses left.
+var errScriptExhausted = errors.New("scripted provider has no responses left")
+
+// scriptedProvider replies to prompts with fixed responses, in order, and
+// records the prompts it was given. It stands in for a language model.
+type scriptedProvider struct {
+	mu        sync.Mutex
+	responses []string
+	prompts   []string
+}
+
+func newScriptedProvider(responses ...string) *scriptedProvider {
+	return &scriptedProvider{
+		responses: responses,
+	}
+}
+
+func (s *scriptedProvider) Model() string {
+	return "scripted"
+}
+
+// Generate ignores the schema, so that tests can script responses a model
+// should not give.
+func (s *scriptedProvider) Generate(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prompts = append(s.prompts, prompt)
+	if len(s.responses) == 0 {
+		return "", errScriptExhausted
+	}
+
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return response, nil
+}
+
+// Prompts returns the prompts the provider was given so far.
+func (s *scriptedProvider) Prompts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.prompts)
+}
+
+func TestScriptedProviderExhausted(t *testing.T) {
+	provider := newScriptedProvider("only")
+
+	if _, err := provider.Generate(context.Background(), "first", nil); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if _, err := provider.Generate(context.Background(), "second", nil); !errors.Is(err, errScriptExhausted) {
+		t.Errorf("Generate past the script returned %v, want errScriptExhausted", err)
+	}
+	if prompts := provider.Prompts(); !slices.Equal(prompts, []string{"first", "second"}) {
+		t.Errorf("Prompts() = %v", prompts)
+	}
+}
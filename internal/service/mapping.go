@@ -0,0 +1,187 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidMapping = errors.New("invalid mapping")
+
+// MappingReview is a terminologist's verdict on a mapping. The reviewer is
+// whoever authenticated the request, never what its body says.
+type MappingReview struct {
+	Reviewer string `json:"-"`
+	Comment  string `json:"comment"`
+}
+
+// MappingEdit replaces the ICD side of a mapping. The display of the ICD
+// code is looked up, so only the code is given, and the reviewer is set like
+// that of a MappingReview.
+type MappingEdit struct {
+	ICDCode     string `json:"icdCode"`
+	Equivalence string `json:"equivalence"`
+	Reviewer    string `json:"-"`
+	Comment     string `json:"comment"`
+}
+
+type MappingService interface {
+	List(status string) ([]repository.Mapping, error)
+	Accept(id uint64, review MappingReview) (*repository.Mapping, error)
+	Reject(id uint64, review MappingReview) (*repository.Mapping, error)
+	Edit(ctx context.Context, id uint64, edit MappingEdit) (*repository.Mapping, error)
+}
+
+type mappingService struct {
+	conceptMapRepository repository.ConceptMapRepository
+	icdRepository        repository.ICDRepository
+}
+
+// List implements MappingService. An empty status lists every mapping.
+func (m *mappingService) List(status string) ([]repository.Mapping, error) {
+	switch status {
+	case "", repository.StatusProposed, repository.StatusAccepted, repository.StatusRejected:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidMapping, status)
+	}
+
+	mappings, err := m.conceptMapRepository.List()
+	if err != nil {
+		return nil, err
+	}
+
+	if status == "" {
+		return mappings, nil
+	}
+
+	result := make([]repository.Mapping, 0)
+	for _, mapping := range mappings {
+		if mappingStatus(mapping) == status {
+			result = append(result, mapping)
+		}
+	}
+
+	return result, nil
+}
+
+// Accept implements MappingService.
+func (m *mappingService) Accept(id uint64, review MappingReview) (*repository.Mapping, error) {
+	return m.review(id, repository.StatusAccepted, review.Reviewer, review.Comment, nil)
+}
+
+// Reject implements MappingService.
+func (m *mappingService) Reject(id uint64, review MappingReview) (*repository.Mapping, error) {
+	return m.review(id, repository.StatusRejected, review.Reviewer, review.Comment, nil)
+}
+
+// Edit implements MappingService. An edited mapping has been curated by the
+// reviewer, so it is accepted as well, once its ICD code is known to exist.
+// A pair that another mapping holds is left to that mapping's review.
+func (m *mappingService) Edit(ctx context.Context, id uint64, edit MappingEdit) (*repository.Mapping, error) {
+	if edit.ICDCode == "" {
+		return nil, fmt.Errorf("%w: icdCode is required", ErrInvalidMapping)
+	}
+	if !validEquivalence(edit.Equivalence) {
+		return nil, fmt.Errorf("%w: unknown equivalence %s", ErrInvalidMapping, edit.Equivalence)
+	}
+
+	match, err := m.resolveICD(ctx, edit.ICDCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.review(id, repository.StatusAccepted, edit.Reviewer, edit.Comment, func(mapping *repository.Mapping) {
+		mapping.ICDCode = match.ID
+		mapping.ICDName = match.Name
+		mapping.Equivalence = edit.Equivalence
+	})
+}
+
+// resolveICD looks up an ICD-11 code or cluster. Clusters are returned
+// written the canonical way, so that they are found however they were
+// typed.
+func (m *mappingService) resolveICD(ctx context.Context, code string) (*repository.ICDMatch, error) {
+	if !repository.IsICDCluster(code) {
+		match, err := m.icdRepository.Get(ctx, code)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown ICD code %s", ErrInvalidMapping, code)
+		}
+		return match, err
+	}
+
+	cluster, err := repository.ParseICDCluster(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMapping, err)
+	}
+
+	match, unknown, err := resolveCluster(ctx, m.icdRepository, m.icdRepository, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if unknown != "" {
+		return nil, fmt.Errorf("%w: unknown ICD code %s in cluster %s", ErrInvalidMapping, unknown, code)
+	}
+
+	return match, nil
+}
+
+func (m *mappingService) review(id uint64, status string, reviewer string, comment string, change func(*repository.Mapping)) (*repository.Mapping, error) {
+	if reviewer == "" {
+		return nil, fmt.Errorf("%w: reviewer is required", ErrInvalidMapping)
+	}
+
+	mapping, err := m.conceptMapRepository.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		change(mapping)
+	}
+	mapping.Status = status
+	mapping.Reviewer = reviewer
+	mapping.Comment = comment
+	mapping.ReviewedAt = time.Now()
+
+	if err := m.conceptMapRepository.Update(*mapping); err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// mappingStatus treats mappings stored before reviews existed as proposed.
+func mappingStatus(mapping repository.Mapping) string {
+	if mapping.Status == "" {
+		return repository.StatusProposed
+	}
+	return mapping.Status
+}
+
+// preferAccepted returns the accepted mappings if there are any, and
+// otherwise the proposed ones. Rejected mappings are never returned.
+func preferAccepted(mappings []repository.Mapping) []repository.Mapping {
+	var accepted, proposed []repository.Mapping
+	for _, mapping := range mappings {
+		switch mappingStatus(mapping) {
+		case repository.StatusAccepted:
+			accepted = append(accepted, mapping)
+		case repository.StatusProposed:
+			proposed = append(proposed, mapping)
+		}
+	}
+
+	if len(accepted) > 0 {
+		return accepted
+	}
+	return proposed
+}
+
+func NewMappingService(conceptMapRepository repository.ConceptMapRepository, icdRepository repository.ICDRepository) MappingService {
+	return &mappingService{
+		conceptMapRepository: conceptMapRepository,
+		icdRepository:        icdRepository,
+	}
+}
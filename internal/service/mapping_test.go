@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"backend/internal/repository"
+	"backend/internal/service"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newMappingService returns a mapping service on the ICD-11 of icdapitest
+// and a concept map holding proposals of SA80 and SA81 for jvaraH, as
+// mappings 1 and 2.
+func newMappingService(t *testing.T) (service.MappingService, repository.ConceptMapRepository) {
+	t.Helper()
+
+	conceptMapRepository, err := repository.NewConceptMapRepository(filepath.Join(t.TempDir(), "conceptmap.db"))
+	if err != nil {
+		t.Fatalf("NewConceptMapRepository: %v", err)
+	}
+	err = conceptMapRepository.Propose([]repository.Mapping{
+		{NamasteType: "ayurveda", NamasteCode: "AAA-1", ICDCode: "SA80", Equivalence: "equivalent"},
+		{NamasteType: "ayurveda", NamasteCode: "AAA-1", ICDCode: "SA81", Equivalence: "wider"},
+	})
+	if err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+
+	return service.NewMappingService(conceptMapRepository, newICDRepository(t)), conceptMapRepository
+}
+
+func listIDs(t *testing.T, mappingService service.MappingService, status string) []uint64 {
+	t.Helper()
+
+	mappings, err := mappingService.List(status)
+	if err != nil {
+		t.Fatalf("List(%q): %v", status, err)
+	}
+	ids := make([]uint64, 0, len(mappings))
+	for _, mapping := range mappings {
+		ids = append(ids, mapping.ID)
+	}
+	return ids
+}
+
+func TestMappingReview(t *testing.T) {
+	tests := []struct {
+		name   string
+		review func(service.MappingService, uint64, service.MappingReview) (*repository.Mapping, error)
+		status string
+	}{
+		{"accept", service.MappingService.Accept, repository.StatusAccepted},
+		{"reject", service.MappingService.Reject, repository.StatusRejected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mappingService, _ := newMappingService(t)
+
+			mapping, err := test.review(mappingService, 1, service.MappingReview{Reviewer: "vaidya", Comment: "checked"})
+			if err != nil {
+				t.Fatalf("review: %v", err)
+			}
+			if mapping.Status != test.status || mapping.Reviewer != "vaidya" || mapping.Comment != "checked" || mapping.ReviewedAt.IsZero() {
+				t.Errorf("reviewed mapping = %+v", mapping)
+			}
+
+			if ids := listIDs(t, mappingService, test.status); !slices.Equal(ids, []uint64{1}) {
+				t.Errorf("List(%s) = %v, want [1]", test.status, ids)
+			}
+			if ids := listIDs(t, mappingService, repository.StatusProposed); !slices.Equal(ids, []uint64{2}) {
+				t.Errorf("List(proposed) = %v, want [2]", ids)
+			}
+
+			if _, err := test.review(mappingService, 1, service.MappingReview{}); !errors.Is(err, service.ErrInvalidMapping) {
+				t.Errorf("review without a reviewer returned %v, want ErrInvalidMapping", err)
+			}
+			if _, err := test.review(mappingService, 99, service.MappingReview{Reviewer: "vaidya"}); service.KindOf(err) != service.KindNotFound {
+				t.Errorf("review of an unknown mapping returned %v, want not found", err)
+			}
+		})
+	}
+}
+
+func TestMappingEdit(t *testing.T) {
+	mappingService, conceptMapRepository := newMappingService(t)
+	ctx := context.Background()
+
+	mapping, err := mappingService.Edit(ctx, 1, service.MappingEdit{ICDCode: "1A00", Equivalence: "narrower", Reviewer: "vaidya"})
+	if err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	if mapping.ICDCode != "1A00" || mapping.ICDName != "Cholera" || mapping.Equivalence != "narrower" || mapping.Status != repository.StatusAccepted {
+		t.Errorf("edited mapping = %+v", mapping)
+	}
+	if mappings, _ := conceptMapRepository.FindByICD("SA80"); len(mappings) != 0 {
+		t.Errorf("FindByICD(SA80) = %v after the edit, want nothing", mappings)
+	}
+
+	tests := []struct {
+		name string
+		edit service.MappingEdit
+		kind service.ErrorKind
+	}{
+		{"no code", service.MappingEdit{Equivalence: "equivalent", Reviewer: "vaidya"}, service.KindInvalid},
+		{"unknown equivalence", service.MappingEdit{ICDCode: "SA80", Equivalence: "same", Reviewer: "vaidya"}, service.KindInvalid},
+		{"unknown code", service.MappingEdit{ICDCode: "1A09", Equivalence: "equivalent", Reviewer: "vaidya"}, service.KindInvalid},
+		{"no reviewer", service.MappingEdit{ICDCode: "SA80", Equivalence: "equivalent"}, service.KindInvalid},
+		{"pair of mapping 2", service.MappingEdit{ICDCode: "SA81", Equivalence: "equivalent", Reviewer: "vaidya"}, service.KindConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := mappingService.Edit(ctx, 1, test.edit); service.KindOf(err) != test.kind {
+				t.Errorf("Edit(%+v) returned %v, want kind %d", test.edit, err, test.kind)
+			}
+		})
+	}
+
+	// A rejection is not overridden by editing another mapping into its pair.
+	if _, err := mappingService.Reject(2, service.MappingReview{Reviewer: "vaidya"}); err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+	if _, err := mappingService.Edit(ctx, 1, service.MappingEdit{ICDCode: "SA81", Equivalence: "equivalent", Reviewer: "vaidya"}); !errors.Is(err, repository.ErrMappingConflict) {
+		t.Errorf("Edit into a rejected pair returned %v, want ErrMappingConflict", err)
+	}
+
+	if ids := listIDs(t, mappingService, repository.StatusRejected); !slices.Equal(ids, []uint64{2}) {
+		t.Errorf("List(rejected) = %v, want [2]", ids)
+	}
+	if mappings, _ := conceptMapRepository.FindByICD("1A00"); len(mappings) != 1 || mappings[0].ID != 1 {
+		t.Errorf("FindByICD(1A00) = %v, want mapping 1 unchanged", mappings)
+	}
+}
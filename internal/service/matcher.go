@@ -0,0 +1,283 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Matcher pairs NAMASTE matches with the ICD-11 matches of each module.
+type Matcher interface {
+	// Name is recorded as the source of the mappings the matcher finds.
+	Name() string
+	Match(ctx context.Context, icdMatches map[string]*repository.ICDMatches, namasteMatches []repository.NamasteMatch) ([]Disease, error)
+}
+
+// modelMatcher asks a language model to pair the matches.
+type modelMatcher struct {
+	provider LLMProvider
+}
+
+const prompt = `
+Here is the ICDRepository response for the ICD-11 biomedicine chapters: %s
+Here is the ICDRepository response for the ICD-11 Traditional Medicine Module 2 (TM2) chapter: %s
+Here is the NamasteRepository response: %s
+I want you to carefully match the corresponding diseases from both responses according to the similarity of their descriptions.
+For dual coding, match every NAMASTE disease with a TM2 disease and with a biomedicine disease where there is one.
+Only use the ICD ids and the NAMASTE types and ids given above, and leave out the NAMASTE diseases that match nothing.
+For every pair, set "equivalence" to describe the ICD disease relative to the NAMASTE disease: "equivalent" if they mean the same thing, "wider" if the ICD disease is broader, "narrower" if it is more specific, or "relatedto" if they are only related.
+Set "confidence" to how sure you are of the pair, from 0 to 1, and "rationale" to one short sentence explaining the pair.
+Return the final output strictly in the following JSON format only:
+{
+  "diseases": [
+    {
+      "icd": {
+        "id": "string"
+      },
+      "namaste": {
+        "type": "string",
+        "id": "string"
+      },
+      "equivalence": "string",
+      "confidence": 0.0,
+      "rationale": "string"
+    }
+  ]
+}
+`
+
+// repairPrompt is appended to the prompt to retry an invalid response.
+const repairPrompt = `
+Your previous response was:
+%s
+It is invalid: %s
+Answer again, fixing these problems.
+`
+
+var matchEquivalences = []string{"equivalent", "wider", "narrower", "relatedto"}
+
+// Name implements Matcher.
+func (m *modelMatcher) Name() string {
+	return m.provider.Model()
+}
+
+// Match implements Matcher. An invalid response is retried once.
+func (m *modelMatcher) Match(ctx context.Context, icdMatches map[string]*repository.ICDMatches, namasteMatches []repository.NamasteMatch) ([]Disease, error) {
+	candidates := newMatchCandidates(icdMatches, namasteMatches)
+	if len(candidates.icd) == 0 || len(candidates.namaste) == 0 {
+		return []Disease{}, nil
+	}
+
+	request := fmt.Sprintf(prompt, icdMatches[ModuleBiomedicine], icdMatches[ModuleTM2], &repository.NamasteMatches{Diseases: namasteMatches})
+	schema := candidates.schema()
+
+	response, err := m.provider.Generate(ctx, request, schema)
+	if err != nil {
+		return nil, &Error{Kind: KindUnavailable, Err: fmt.Errorf("language model unavailable: %w", err)}
+	}
+
+	diseases, err := candidates.decode(response)
+	if err == nil {
+		return diseases, nil
+	}
+
+	log.Println("Error: invalid language model response: " + err.Error() + ", retrying")
+
+	response, err = m.provider.Generate(ctx, request+fmt.Sprintf(repairPrompt, response, err), schema)
+	if err != nil {
+		return nil, &Error{Kind: KindUnavailable, Err: fmt.Errorf("language model unavailable: %w", err)}
+	}
+
+	diseases, err = candidates.decode(response)
+	if err != nil {
+		return nil, &Error{Kind: KindUpstream, Err: fmt.Errorf("invalid language model response: %w", err)}
+	}
+
+	return diseases, nil
+}
+
+// modelResponse is the response the model is asked for.
+type modelResponse struct {
+	Diseases []struct {
+		ICD struct {
+			ID string `json:"id"`
+		} `json:"icd"`
+		Namaste struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		} `json:"namaste"`
+		Equivalence string  `json:"equivalence"`
+		Confidence  float64 `json:"confidence"`
+		Rationale   string  `json:"rationale"`
+	} `json:"diseases"`
+}
+
+// matchCandidates holds the matches the model may pair.
+type matchCandidates struct {
+	icd     map[string]repository.ICDMatch
+	namaste map[string]repository.NamasteMatch
+}
+
+func newMatchCandidates(icdMatches map[string]*repository.ICDMatches, namasteMatches []repository.NamasteMatch) *matchCandidates {
+	candidates := &matchCandidates{
+		icd:     make(map[string]repository.ICDMatch),
+		namaste: make(map[string]repository.NamasteMatch),
+	}
+
+	for _, matches := range icdMatches {
+		if matches == nil {
+			continue
+		}
+		for _, match := range matches.Matches {
+			candidates.icd[match.ID] = match
+		}
+	}
+
+	for _, match := range namasteMatches {
+		candidates.namaste[match.Type+"/"+match.ID] = match
+	}
+
+	return candidates
+}
+
+// schema returns the JSON schema of modelResponse, limited to the
+// candidates.
+func (c *matchCandidates) schema() map[string]any {
+	icdIDs := slices.Sorted(maps.Keys(c.icd))
+
+	namasteTypes := make([]string, 0)
+	namasteIDs := make([]string, 0)
+	for _, match := range c.namaste {
+		if !slices.Contains(namasteTypes, match.Type) {
+			namasteTypes = append(namasteTypes, match.Type)
+		}
+		if !slices.Contains(namasteIDs, match.ID) {
+			namasteIDs = append(namasteIDs, match.ID)
+		}
+	}
+	slices.Sort(namasteTypes)
+	slices.Sort(namasteIDs)
+
+	return objectSchema(map[string]any{
+		"diseases": map[string]any{
+			"type": "array",
+			"items": objectSchema(map[string]any{
+				"icd": objectSchema(map[string]any{
+					"id": enumSchema(icdIDs),
+				}),
+				"namaste": objectSchema(map[string]any{
+					"type": enumSchema(namasteTypes),
+					"id":   enumSchema(namasteIDs),
+				}),
+				"equivalence": enumSchema(matchEquivalences),
+				"confidence": map[string]any{
+					"type":    "number",
+					"minimum": 0,
+					"maximum": 1,
+				},
+				"rationale": map[string]any{
+					"type": "string",
+				},
+			}),
+		},
+	})
+}
+
+// decode parses a response and checks every pair against the candidates.
+func (c *matchCandidates) decode(response string) ([]Disease, error) {
+	var parsed modelResponse
+	if err := json.Unmarshal([]byte(extractJSON(response)), &parsed); err != nil {
+		return nil, fmt.Errorf("not the requested JSON: %w", err)
+	}
+
+	diseases := make([]Disease, 0, len(parsed.Diseases))
+	var problems []error
+	for i, pair := range parsed.Diseases {
+		icd, ok := c.icd[pair.ICD.ID]
+		if !ok {
+			problems = append(problems, fmt.Errorf("diseases[%d]: ICD id %q is not one of the given ICD ids", i, pair.ICD.ID))
+		}
+
+		namaste, found := c.namaste[pair.Namaste.Type+"/"+pair.Namaste.ID]
+		if !found {
+			problems = append(problems, fmt.Errorf("diseases[%d]: NAMASTE %s id %q is not one of the given NAMASTE diseases", i, pair.Namaste.Type, pair.Namaste.ID))
+		}
+
+		if !slices.Contains(matchEquivalences, pair.Equivalence) {
+			problems = append(problems, fmt.Errorf("diseases[%d]: equivalence %q is not one of %s", i, pair.Equivalence, strings.Join(matchEquivalences, ", ")))
+		}
+
+		if pair.Confidence < 0 || pair.Confidence > 1 {
+			problems = append(problems, fmt.Errorf("diseases[%d]: confidence %v is not between 0 and 1", i, pair.Confidence))
+		}
+
+		if !ok || !found {
+			continue
+		}
+
+		diseases = append(diseases, Disease{
+			ICD: ICD{
+				ID:   icd.ID,
+				Name: icd.Name,
+				Desc: icd.Desc,
+			},
+			Namaste: Namaste{
+				Type: namaste.Type,
+				ID:   namaste.ID,
+				Name: namaste.Name,
+				Desc: namaste.Desc,
+			},
+			Equivalence: pair.Equivalence,
+			Confidence:  pair.Confidence,
+			Method:      MethodLLM,
+			Rationale:   pair.Rationale,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+
+	return diseases, nil
+}
+
+// objectSchema returns the schema of an object requiring all of properties,
+// as strict structured output expects.
+func objectSchema(properties map[string]any) map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             slices.Sorted(maps.Keys(properties)),
+		"additionalProperties": false,
+	}
+}
+
+func enumSchema(values []string) map[string]any {
+	return map[string]any{
+		"type": "string",
+		"enum": values,
+	}
+}
+
+// extractJSON strips the prose or code fence around the JSON of a response.
+func extractJSON(response string) string {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return response
+	}
+
+	return response[start : end+1]
+}
+
+func NewModelMatcher(provider LLMProvider) Matcher {
+	return &modelMatcher{
+		provider: provider,
+	}
+}
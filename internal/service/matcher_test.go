@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"backend/internal/repository"
+	"backend/internal/service"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testICDMatches and testNamasteMatches are the candidates the model is
+// asked to pair in the matcher tests.
+var (
+	testICDMatches = map[string]*repository.ICDMatches{
+		service.ModuleBiomedicine: {Matches: []repository.ICDMatch{{ID: "MG26", Name: "Fever of other or unknown origin"}}},
+		service.ModuleTM2:         {Matches: []repository.ICDMatch{{ID: "SK60", Name: "Fever disorder (TM2)"}}},
+	}
+	testNamasteMatches = []repository.NamasteMatch{
+		{Type: "ayurveda", ID: "AAA-1", Name: "jvaraH", Desc: "Fever"},
+	}
+)
+
+// pairResponse returns a model response pairing one ICD code with one
+// NAMASTE code.
+func pairResponse(icdID string, namasteType string, namasteID string, equivalence string) string {
+	return fmt.Sprintf(`{"diseases":[{"icd":{"id":%q},"namaste":{"type":%q,"id":%q},"equivalence":%q,"confidence":0.9,"rationale":"Both are fevers."}]}`, icdID, namasteType, namasteID, equivalence)
+}
+
+func TestModelMatcherMatch(t *testing.T) {
+	valid := pairResponse("SK60", "ayurveda", "AAA-1", "equivalent")
+
+	tests := []struct {
+		name      string
+		responses []string
+		// prompts is the number of times the model is asked.
+		prompts int
+		// repair is what the second prompt says was wrong, if there is one.
+		repair string
+		// fails tells whether Match fails, with an error of kind.
+		fails bool
+		kind  service.ErrorKind
+	}{
+		{name: "valid", responses: []string{valid}, prompts: 1},
+		{name: "fenced", responses: []string{"```json\n" + valid + "\n```"}, prompts: 1},
+		{name: "prose", responses: []string{"Here are the matches:\n" + valid + "\nLet me know if you need more."}, prompts: 1},
+		{
+			name:      "unknown ICD id",
+			responses: []string{pairResponse("1A00", "ayurveda", "AAA-1", "equivalent"), valid},
+			prompts:   2,
+			repair:    `ICD id "1A00" is not one of the given ICD ids`,
+		},
+		{
+			name:      "unknown NAMASTE id",
+			responses: []string{pairResponse("SK60", "ayurveda", "AAA-9", "equivalent"), valid},
+			prompts:   2,
+			repair:    `NAMASTE ayurveda id "AAA-9" is not one of the given NAMASTE diseases`,
+		},
+		{
+			name:      "unknown NAMASTE type",
+			responses: []string{pairResponse("SK60", "siddha", "AAA-1", "equivalent"), valid},
+			prompts:   2,
+			repair:    `NAMASTE siddha id "AAA-1" is not one of the given NAMASTE diseases`,
+		},
+		{
+			name:      "bad equivalence",
+			responses: []string{pairResponse("SK60", "ayurveda", "AAA-1", "same"), valid},
+			prompts:   2,
+			repair:    `equivalence "same" is not one of`,
+		},
+		{
+			name:      "not JSON",
+			responses: []string{"I could not find any matches.", valid},
+			prompts:   2,
+			repair:    "not the requested JSON",
+		},
+		{
+			name:      "invalid twice",
+			responses: []string{pairResponse("1A00", "ayurveda", "AAA-1", "equivalent"), pairResponse("SK60", "ayurveda", "AAA-1", "same")},
+			prompts:   2,
+			fails:     true,
+			kind:      service.KindUpstream,
+		},
+		{
+			name:      "unavailable for the repair",
+			responses: []string{"not JSON"},
+			prompts:   2,
+			fails:     true,
+			kind:      service.KindUnavailable,
+		},
+		{name: "unavailable", prompts: 1, fails: true, kind: service.KindUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newScriptedProvider(test.responses...)
+			diseases, err := service.NewModelMatcher(provider).Match(context.Background(), testICDMatches, testNamasteMatches)
+
+			prompts := provider.Prompts()
+			if len(prompts) != test.prompts {
+				t.Errorf("model asked %d times, want %d", len(prompts), test.prompts)
+			}
+			if test.repair != "" && len(prompts) == 2 && !strings.Contains(prompts[1], test.repair) {
+				t.Errorf("repair prompt does not say %q:\n%s", test.repair, prompts[1])
+			}
+
+			if test.fails {
+				if got := service.KindOf(err); got != test.kind {
+					t.Errorf("Match returned %v of kind %v, want kind %v", err, got, test.kind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+
+			// Names come from the candidates, not from the model.
+			if len(diseases) != 1 {
+				t.Fatalf("Match returned %v, want one pair", diseases)
+			}
+			disease := diseases[0]
+			if disease.ICD.ID != "SK60" || disease.ICD.Name != "Fever disorder (TM2)" || disease.Namaste.ID != "AAA-1" || disease.Namaste.Name != "jvaraH" {
+				t.Errorf("Match paired %+v", disease)
+			}
+			if disease.Equivalence != "equivalent" || disease.Confidence != 0.9 || disease.Method != service.MethodLLM || disease.Rationale == "" {
+				t.Errorf("Match rated the pair %+v", disease)
+			}
+		})
+	}
+}
+
+func TestModelMatcherWithoutCandidates(t *testing.T) {
+	provider := newScriptedProvider()
+
+	diseases, err := service.NewModelMatcher(provider).Match(context.Background(), testICDMatches, nil)
+	if err != nil || len(diseases) != 0 {
+		t.Errorf("Match without NAMASTE matches returned %v, %v, want nothing", diseases, err)
+	}
+	if len(provider.Prompts()) != 0 {
+		t.Error("model asked without NAMASTE matches")
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/repository"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Route is an endpoint registered on the server.
+type Route struct {
+	Method string
+	Path   string
+}
+
+type MetadataService interface {
+	CapabilityStatement(routes []Route, baseURL string) *dto.CapabilityStatement
+	TerminologyCapabilities(routes []Route) *dto.TerminologyCapabilities
+}
+
+type metadataService struct {
+	name        string
+	version     string
+	description string
+	icdRelease  string
+}
+
+// fhirResources maps the first path segment of a route to the FHIR resource
+// it serves. Routes outside these segments are not FHIR endpoints.
+var fhirResources = map[string]string{
+	"codesystem": "CodeSystem",
+	"valueset":   "ValueSet",
+	"conceptmap": "ConceptMap",
+}
+
+// CapabilityStatement implements MetadataService. The resources and
+// operations are derived from the registered routes, so the statement never
+// claims more than the server serves. Route paths are relative to baseURL.
+func (m *metadataService) CapabilityStatement(routes []Route, baseURL string) *dto.CapabilityStatement {
+	resources := make([]dto.RestResource, 0)
+	indexes := make(map[string]int)
+
+	for _, route := range routes {
+		path, ok := strings.CutPrefix(route.Path, "/")
+		if !ok {
+			continue
+		}
+
+		segment, rest, _ := strings.Cut(path, "/")
+		resourceType, ok := fhirResources[segment]
+		if !ok {
+			continue
+		}
+
+		i, ok := indexes[resourceType]
+		if !ok {
+			i = len(resources)
+			indexes[resourceType] = i
+			resources = append(resources, dto.RestResource{Type: resourceType})
+		}
+		resource := &resources[i]
+
+		if name, ok := strings.CutPrefix(rest, "$"); ok {
+			operation := dto.Operation{
+				Name:       name,
+				Definition: "http://hl7.org/fhir/OperationDefinition/" + resourceType + "-" + name,
+			}
+			if !slices.Contains(resource.Operation, operation) {
+				resource.Operation = append(resource.Operation, operation)
+			}
+		} else if route.Method == "GET" && !slices.Contains(resource.Interaction, dto.Interaction{Code: "read"}) {
+			resource.Interaction = append(resource.Interaction, dto.Interaction{Code: "read"})
+		}
+	}
+
+	return &dto.CapabilityStatement{
+		ResourceType: "CapabilityStatement",
+		Status:       "active",
+		Date:         time.Now().Format(time.RFC3339),
+		Kind:         "instance",
+		Software: dto.Software{
+			Name:    m.name,
+			Version: m.version,
+		},
+		Implementation: dto.Implementation{
+			Description: m.description,
+			URL:         baseURL,
+		},
+		FHIRVersion: "4.0.1",
+		Format:      []string{"json"},
+		Rest: []dto.Rest{
+			{
+				Mode:     "server",
+				Resource: resources,
+			},
+		},
+	}
+}
+
+// TerminologyCapabilities implements MetadataService.
+func (m *metadataService) TerminologyCapabilities(routes []Route) *dto.TerminologyCapabilities {
+	result := &dto.TerminologyCapabilities{
+		ResourceType: "TerminologyCapabilities",
+		Status:       "active",
+		Date:         time.Now().Format(time.RFC3339),
+		Kind:         "instance",
+		Software: dto.Software{
+			Name:    m.name,
+			Version: m.version,
+		},
+		CodeSystem: []dto.TerminologyCodeSystem{
+			{
+				URI:     ICDSystem,
+				Version: []dto.CodeSystemVersion{{Code: m.icdRelease, IsDefault: true}},
+			},
+			{
+				URI:     ICDTM2System,
+				Version: []dto.CodeSystemVersion{{Code: m.icdRelease, IsDefault: true}},
+			},
+			{
+				URI:     NamasteSystem,
+				Version: []dto.CodeSystemVersion{{Code: namasteVersion, IsDefault: true}},
+			},
+		},
+	}
+
+	for _, branch := range repository.Branches {
+		result.CodeSystem = append(result.CodeSystem, dto.TerminologyCodeSystem{
+			URI:     NamasteSystem + "/" + branch,
+			Version: []dto.CodeSystemVersion{{Code: namasteVersion, IsDefault: true}},
+		})
+	}
+
+	for _, route := range routes {
+		switch {
+		case strings.HasSuffix(route.Path, "/valueset/$expand"):
+			result.Expansion = &dto.TerminologyExpansion{
+				Paging:     true,
+				TextFilter: "Matches codes whose terms or definitions contain the words of the filter",
+			}
+		case strings.HasSuffix(route.Path, "/$validate-code"):
+			result.ValidateCode = &dto.TerminologyValidate{}
+		case strings.HasSuffix(route.Path, "/conceptmap/$translate"):
+			result.Translation = &dto.TerminologyTranslation{}
+		}
+	}
+
+	return result
+}
+
+func NewMetadataService(name string, version string, description string, icdRelease string) MetadataService {
+	return &metadataService{
+		name:        name,
+		version:     version,
+		description: description,
+		icdRelease:  icdRelease,
+	}
+}
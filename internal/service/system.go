@@ -0,0 +1,105 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"errors"
+	"strings"
+)
+
+const (
+	// NamasteSystem is the canonical URL of the NAMASTE code system. Each
+	// branch is also addressable as NamasteSystem + "/" + branch, which is
+	// needed to tell apart codes that repeat across branches.
+	NamasteSystem = "https://backend-kl02.onrender.com/api/v1/codesystem/namaste"
+	// ICDSystem is the canonical URL of the ICD-11 code system.
+	ICDSystem = "https://backend-kl02.onrender.com/api/v1/codesystem/icd"
+	// ICDTM2System is the canonical URL of the ICD-11 Traditional Medicine
+	// Module 2, the codes of chapter 26 that are not in Module I. Its codes
+	// are ICD-11 codes, so they are valid in ICDSystem as well.
+	ICDTM2System = "https://backend-kl02.onrender.com/api/v1/codesystem/icd-tm2"
+	// ConceptMapURL is the canonical URL of the NAMASTE to ICD-11 concept map.
+	ConceptMapURL = "https://backend-kl02.onrender.com/api/v1/conceptmap"
+
+	// SourceSystemExtension tells whether a code is from NAMASTE or ICD-11.
+	SourceSystemExtension = "https://backend-kl02.onrender.com/api/v1/structuredefinition/sourceSystem"
+	// ConfidenceExtension, MethodExtension and RationaleExtension describe
+	// how a NAMASTE and an ICD-11 code were paired: the Confidence, Method
+	// and Rationale of a Disease.
+	ConfidenceExtension = "https://backend-kl02.onrender.com/api/v1/structuredefinition/pairingConfidence"
+	MethodExtension     = "https://backend-kl02.onrender.com/api/v1/structuredefinition/pairingMethod"
+	RationaleExtension  = "https://backend-kl02.onrender.com/api/v1/structuredefinition/pairingRationale"
+
+	// Version reported for the NAMASTE code system. ICD-11 reports the
+	// release it is served from.
+	namasteVersion = "1.0"
+
+	// icdCanonicalSystem is WHO's own URL for ICD-11 MMS, accepted as an alias
+	// of ICDSystem.
+	icdCanonicalSystem = "http://id.who.int/icd/release/11/mms"
+)
+
+var (
+	ErrUnknownSystem = errors.New("unknown code system")
+	ErrAmbiguousCode = errors.New("code exists in more than one NAMASTE branch")
+)
+
+// nativeLanguages holds the language of the native term of each branch.
+var nativeLanguages = map[string]string{
+	"ayurveda": "sa",
+	"siddha":   "ta",
+	"unani":    "ur",
+}
+
+// namasteBranch reports whether system is the NAMASTE code system or one of
+// its branches. The branch is empty for the umbrella system.
+func namasteBranch(system string) (string, bool) {
+	if system == NamasteSystem {
+		return "", true
+	}
+
+	branch, ok := strings.CutPrefix(system, NamasteSystem+"/")
+	if !ok {
+		return "", false
+	}
+
+	for _, b := range repository.Branches {
+		if b == branch {
+			return branch, true
+		}
+	}
+
+	return "", false
+}
+
+// icdChapters reports whether system is ICD-11 or its TM2 module, and returns
+// the chapters it covers. Nil chapters means the whole linearization.
+func icdChapters(system string) ([]string, bool) {
+	switch system {
+	case ICDSystem, icdCanonicalSystem:
+		return nil, true
+	case ICDTM2System:
+		return repository.TM2Chapters, true
+	}
+
+	return nil, false
+}
+
+func isICDSystem(system string) bool {
+	_, ok := icdChapters(system)
+	return ok
+}
+
+// icdSystem returns the system of an ICD-11 code: ICDTM2System for TM2
+// codes, and ICDSystem for the others.
+func icdSystem(code string) string {
+	if icdModule(code) == ModuleTM2 {
+		return ICDTM2System
+	}
+
+	return ICDSystem
+}
+
+func isNamasteSystem(system string) bool {
+	_, ok := namasteBranch(system)
+	return ok
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+// SuggestOptions are the optional inputs of TypeaheadService.Suggest.
+type SuggestOptions struct {
+	// System is a NAMASTE branch system; NamasteSystem or empty is every branch.
+	System string
+	// Language is guessed from the script of the input when empty.
+	Language string
+	Count    int
+}
+
+type TypeaheadService interface {
+	Suggest(ctx context.Context, input string, options SuggestOptions) (*dto.ValueSet, error)
+}
+
+// typeaheadService completes NAMASTE terms from the local index only.
+type typeaheadService struct {
+	namasteRepository repository.NamasteRepository
+}
+
+// Suggest implements TypeaheadService.
+func (t *typeaheadService) Suggest(ctx context.Context, input string, options SuggestOptions) (*dto.ValueSet, error) {
+	branch := ""
+	if options.System != "" {
+		var ok bool
+		branch, ok = namasteBranch(options.System)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSystem, options.System)
+		}
+	}
+
+	records, err := t.namasteRepository.Suggest(ctx, input, options.Language, branch, options.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	contains := make([]dto.Contain, 0, len(records))
+	for _, record := range records {
+		contains = append(contains, namasteContain(record, true))
+	}
+
+	total := len(contains)
+
+	return &dto.ValueSet{
+		ResourceType: "ValueSet",
+		ID:           "typeahead",
+		Status:       "active",
+		Expansion: dto.Expansion{
+			Identifier: "https://backend-kl02.onrender.com/api/v1/typeahead",
+			Timestamp:  time.Now(),
+			Total:      &total,
+			Offset:     0,
+			Contains:   contains,
+		},
+	}, nil
+}
+
+func NewTypeaheadService(namasteRepository repository.NamasteRepository) TypeaheadService {
+	return &typeaheadService{
+		namasteRepository: namasteRepository,
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownValueSet = errors.New("unknown value set")
+	// ErrExpansionTooLarge is returned for a page of an expansion that is too
+	// far in to be reached.
+	ErrExpansionTooLarge = errors.New("expansion too large")
+)
+
+// maxUnfilteredICD bounds offset+count of an ICD-11 expansion without a
+// filter, which is listed from the start of the linearization.
+const maxUnfilteredICD = 5000
+
+// ExpandOptions are the inputs of ValueSet/$expand.
+type ExpandOptions struct {
+	VersionOptions
+	Filter              string
+	Offset              int
+	Count               int
+	IncludeDesignations bool
+	ActiveOnly          bool
+}
+
+type ValueSetService interface {
+	ValidateCode(ctx context.Context, url string, system string, code string, display string, options VersionOptions) (*dto.Parameters, error)
+	Expand(ctx context.Context, url string, options ExpandOptions) (*dto.ValueSet, error)
+}
+
+type valueSetService struct {
+	codeSystemService CodeSystemService
+	namasteRepository repository.NamasteRepository
+	icdRepository     repository.ICDRepository
+}
+
+// ValidateCode implements ValueSetService. Only the implicit "all codes"
+// value sets of the supported code systems exist, so validating against a
+// value set is validating against its code system.
+func (v *valueSetService) ValidateCode(ctx context.Context, url string, system string, code string, display string, options VersionOptions) (*dto.Parameters, error) {
+	if url != "" {
+		vsSystem, ok := strings.CutSuffix(url, "?fhir_vs")
+		if !ok || (!isICDSystem(vsSystem) && !isNamasteSystem(vsSystem)) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownValueSet, url)
+		}
+
+		if system == "" {
+			system = vsSystem
+		}
+
+		if system != vsSystem {
+			return validationResult(false, fmt.Sprintf("Code system %s is not included in value set %s", system, url), ""), nil
+		}
+	}
+
+	return v.codeSystemService.ValidateCode(ctx, system, code, display, options)
+}
+
+// Expand implements ValueSetService. Every code of both code systems is
+// active, so ActiveOnly never removes anything.
+func (v *valueSetService) Expand(ctx context.Context, url string, options ExpandOptions) (*dto.ValueSet, error) {
+	system, ok := strings.CutSuffix(url, "?fhir_vs")
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownValueSet, url)
+	}
+
+	var (
+		id       string
+		contains []dto.Contain
+		total    *int
+		err      error
+	)
+
+	switch {
+	case system == ICDTM2System:
+		id = "icd-tm2"
+		contains, total, err = v.expandICD(ctx, system, v.icdRepository.Chapters(repository.TM2Chapters), options)
+	case isICDSystem(system):
+		id = "icd"
+		contains, total, err = v.expandICD(ctx, ICDSystem, v.icdRepository, options)
+	case isNamasteSystem(system):
+		branch, _ := namasteBranch(system)
+		id = strings.TrimSuffix("namaste-"+branch, "-")
+		contains, total, err = v.expandNamaste(ctx, branch, options)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownValueSet, url)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ValueSet{
+		ResourceType: "ValueSet",
+		ID:           id,
+		URL:          url,
+		Status:       "active",
+		Expansion: dto.Expansion{
+			Identifier: "https://backend-kl02.onrender.com/api/v1/valueset/$expand",
+			Timestamp:  time.Now(),
+			Total:      total,
+			Offset:     options.Offset,
+			Contains:   contains,
+		},
+	}, nil
+}
+
+// expandICD returns a page of the ICD-11 codes matching the filter, or of
+// all of them, with their total number if it is known.
+func (v *valueSetService) expandICD(ctx context.Context, system string, icdRepository repository.ICDRepository, options ExpandOptions) ([]dto.Contain, *int, error) {
+	icdRepository, err := withVersion(ctx, icdRepository, options.VersionOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		matches []repository.ICDMatch
+		total   *int
+	)
+
+	if options.Filter == "" {
+		matches, total, err = listICDPage(ctx, icdRepository, options.Offset, options.Count)
+	} else {
+		var n int
+		matches, n, err = icdRepository.Search(ctx, options.Filter, options.Offset, options.Count)
+		total = &n
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contains := make([]dto.Contain, 0, len(matches))
+	for _, match := range matches {
+		contains = append(contains, dto.Contain{
+			System:    system,
+			Version:   icdRepository.Edition().Release,
+			Code:      match.ID,
+			Display:   match.Name,
+			Abstract:  match.Kind == "chapter" || match.Kind == "block",
+			Extension: []dto.Extension{{URL: SourceSystemExtension, ValueString: "ICD"}},
+		})
+	}
+
+	return contains, total, nil
+}
+
+// listICDPage lists the codes of icdRepository up to the end of the page. The
+// total is nil unless the listing ends within the page.
+func listICDPage(ctx context.Context, icdRepository repository.ICDRepository, offset int, count int) ([]repository.ICDMatch, *int, error) {
+	if offset+count > maxUnfilteredICD {
+		return nil, nil, fmt.Errorf("%w: offset and count must add up to at most %d without a filter", ErrExpansionTooLarge, maxUnfilteredICD)
+	}
+
+	// One more code than asked for tells whether the listing goes on.
+	matches, err := icdRepository.List(ctx, offset+count+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var total *int
+	if len(matches) <= offset+count {
+		n := len(matches)
+		total = &n
+	} else {
+		matches = matches[:offset+count]
+	}
+
+	return matches[min(offset, len(matches)):], total, nil
+}
+
+func (v *valueSetService) expandNamaste(ctx context.Context, branch string, options ExpandOptions) ([]dto.Contain, *int, error) {
+	records, total, err := v.namasteRepository.Search(ctx, options.Filter, branch, options.Offset, options.Count)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contains := make([]dto.Contain, 0, len(records))
+	for _, record := range records {
+		contains = append(contains, namasteContain(record, options.IncludeDesignations))
+	}
+
+	n := int(total)
+	return contains, &n, nil
+}
+
+// namasteContain returns a NAMASTE record as an expansion entry, with its
+// native term as a designation if includeDesignations is set.
+func namasteContain(record repository.Record, includeDesignations bool) dto.Contain {
+	contain := dto.Contain{
+		System:    NamasteSystem + "/" + record.Type,
+		Code:      record.Code,
+		Display:   record.Diacritical,
+		Extension: []dto.Extension{{URL: SourceSystemExtension, ValueString: "NAMASTE"}},
+	}
+
+	if includeDesignations && record.Native != "" {
+		contain.Designation = []dto.Designation{
+			{Language: nativeLanguages[record.Type], Value: record.Native},
+		}
+	}
+
+	return contain
+}
+
+func NewValueSetService(codeSystemService CodeSystemService, namasteRepository repository.NamasteRepository, icdRepository repository.ICDRepository) ValueSetService {
+	return &valueSetService{
+		codeSystemService: codeSystemService,
+		namasteRepository: namasteRepository,
+		icdRepository:     icdRepository,
+	}
+}
@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"backend/cmd/web/dto"
+	"backend/internal/repository"
+	"backend/internal/repository/icdapitest"
+	"backend/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// namasteRecords is the number of records of the NAMASTE test assets, of
+// which siddhaRecords are Siddha.
+const (
+	namasteRecords = 12
+	siddhaRecords  = 5
+)
+
+// newICDRepository returns a repository on the ICD-11 of icdapitest.
+func newICDRepository(t *testing.T) repository.ICDRepository {
+	t.Helper()
+
+	server := icdapitest.NewServer()
+	t.Cleanup(server.Close)
+
+	icdRepository, err := repository.NewICDRepository(server.Client(), server.Endpoint(), repository.WHOClientOptions{Timeout: 5 * time.Second, BreakerThreshold: 100}, nil, repository.ICDEdition{})
+	if err != nil {
+		t.Fatalf("NewICDRepository: %v", err)
+	}
+	return icdRepository
+}
+
+// newNamasteRepository returns a repository on an index of the NAMASTE test
+// assets.
+func newNamasteRepository(t *testing.T) repository.NamasteRepository {
+	t.Helper()
+
+	namasteRepository, err := repository.NewNamasteRepository(filepath.Join(t.TempDir(), "index.bleve"), "../repository/testdata/namaste")
+	if err != nil {
+		t.Fatalf("NewNamasteRepository: %v", err)
+	}
+	return namasteRepository
+}
+
+func newValueSetService(t *testing.T) service.ValueSetService {
+	t.Helper()
+
+	namasteRepository, icdRepository := newNamasteRepository(t), newICDRepository(t)
+	return service.NewValueSetService(service.NewCodeSystemService(namasteRepository, icdRepository), namasteRepository, icdRepository)
+}
+
+func containCodes(contains []dto.Contain) []string {
+	codes := make([]string, 0, len(contains))
+	for _, contain := range contains {
+		codes = append(codes, contain.Code)
+	}
+	return codes
+}
+
+func TestValueSetExpandNamaste(t *testing.T) {
+	valueSetService := newValueSetService(t)
+
+	tests := []struct {
+		name      string
+		url       string
+		options   service.ExpandOptions
+		total     int
+		contains  int
+		firstCode string
+	}{
+		{"first page", service.NamasteSystem + "?fhir_vs", service.ExpandOptions{Count: 5}, namasteRecords, 5, ""},
+		{"last page", service.NamasteSystem + "?fhir_vs", service.ExpandOptions{Offset: 10, Count: 5}, namasteRecords, namasteRecords - 10, ""},
+		{"past the end", service.NamasteSystem + "?fhir_vs", service.ExpandOptions{Offset: 20, Count: 5}, namasteRecords, 0, ""},
+		{"branch", service.NamasteSystem + "/siddha?fhir_vs", service.ExpandOptions{Count: 10}, siddhaRecords, siddhaRecords, ""},
+		{"filter", service.NamasteSystem + "/siddha?fhir_vs", service.ExpandOptions{Filter: "kasarogam", Count: 10}, 1, 1, "SB-2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valueSet, err := valueSetService.Expand(context.Background(), test.url, test.options)
+			if err != nil {
+				t.Fatalf("Expand: %v", err)
+			}
+
+			expansion := valueSet.Expansion
+			if expansion.Total == nil || *expansion.Total != test.total || len(expansion.Contains) != test.contains || expansion.Offset != test.options.Offset {
+				t.Fatalf("Expand returned %d codes of %v at %d, want %d of %d at %d", len(expansion.Contains), expansion.Total, expansion.Offset, test.contains, test.total, test.options.Offset)
+			}
+			if test.firstCode != "" && expansion.Contains[0].Code != test.firstCode {
+				t.Errorf("Expand returned %v, want %s first", containCodes(expansion.Contains), test.firstCode)
+			}
+		})
+	}
+
+	// Pages do not overlap.
+	first, _ := valueSetService.Expand(context.Background(), service.NamasteSystem+"?fhir_vs", service.ExpandOptions{Count: 6})
+	second, _ := valueSetService.Expand(context.Background(), service.NamasteSystem+"?fhir_vs", service.ExpandOptions{Offset: 6, Count: 6})
+	seen := make(map[string]bool)
+	for _, contain := range append(first.Expansion.Contains, second.Expansion.Contains...) {
+		key := contain.System + "|" + contain.Code
+		if seen[key] {
+			t.Errorf("%s is on both pages", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) != namasteRecords {
+		t.Errorf("pages hold %d codes, want %d", len(seen), namasteRecords)
+	}
+}
+
+func TestValueSetExpandDesignations(t *testing.T) {
+	valueSetService := newValueSetService(t)
+
+	valueSet, err := valueSetService.Expand(context.Background(), service.NamasteSystem+"/siddha?fhir_vs", service.ExpandOptions{Filter: "kasarogam", Count: 1, IncludeDesignations: true})
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	designations := valueSet.Expansion.Contains[0].Designation
+	if len(designations) != 1 || designations[0].Language != "ta" || designations[0].Value != "காசரோகம்" {
+		t.Errorf("designations = %+v, want the Tamil term", designations)
+	}
+}
+
+func TestValueSetExpandICD(t *testing.T) {
+	valueSetService := newValueSetService(t)
+
+	tests := []struct {
+		name    string
+		url     string
+		options service.ExpandOptions
+		// total is -1 when the total is not known.
+		total int
+		codes []string
+	}{
+		{"whole listing", service.ICDSystem + "?fhir_vs", service.ExpandOptions{Count: 100}, 8, []string{"01", "BlockL1-1A0", "1A00", "1A01", "26", "SA80", "SA81", "SK60"}},
+		{"page", service.ICDSystem + "?fhir_vs", service.ExpandOptions{Offset: 2, Count: 3}, -1, []string{"1A00", "1A01", "26"}},
+		{"last page", service.ICDSystem + "?fhir_vs", service.ExpandOptions{Offset: 6, Count: 5}, 8, []string{"SA81", "SK60"}},
+		{"filter", service.ICDSystem + "?fhir_vs", service.ExpandOptions{Filter: "cholera", Count: 1}, 2, []string{"1A00"}},
+		{"TM2", service.ICDTM2System + "?fhir_vs", service.ExpandOptions{Count: 100}, 2, []string{"26", "SK60"}},
+		{"TM2 filter", service.ICDTM2System + "?fhir_vs", service.ExpandOptions{Filter: "fever", Count: 10}, 1, []string{"SK60"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valueSet, err := valueSetService.Expand(context.Background(), test.url, test.options)
+			if err != nil {
+				t.Fatalf("Expand: %v", err)
+			}
+
+			expansion := valueSet.Expansion
+			if codes := containCodes(expansion.Contains); !slices.Equal(codes, test.codes) {
+				t.Errorf("Expand returned %v, want %v", codes, test.codes)
+			}
+
+			body, err := json.Marshal(expansion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case test.total < 0 && (expansion.Total != nil || strings.Contains(string(body), `"total"`)):
+				t.Errorf("Expand returned a total of %d, want it left out", *expansion.Total)
+			case test.total >= 0 && (expansion.Total == nil || *expansion.Total != test.total):
+				t.Errorf("Expand returned a total of %v, want %d", expansion.Total, test.total)
+			}
+		})
+	}
+}
+
+func TestValueSetExpandErrors(t *testing.T) {
+	valueSetService := newValueSetService(t)
+
+	tests := []struct {
+		name    string
+		url     string
+		options service.ExpandOptions
+		err     error
+	}{
+		{"not an implicit value set", service.ICDSystem, service.ExpandOptions{Count: 10}, service.ErrUnknownValueSet},
+		{"unknown system", "http://snomed.info/sct?fhir_vs", service.ExpandOptions{Count: 10}, service.ErrUnknownValueSet},
+		{"too far without a filter", service.ICDSystem + "?fhir_vs", service.ExpandOptions{Offset: 4990, Count: 20}, service.ErrExpansionTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := valueSetService.Expand(context.Background(), test.url, test.options)
+			if !errors.Is(err, test.err) || service.KindOf(err) != service.KindInvalid {
+				t.Errorf("Expand returned %v, want %v", err, test.err)
+			}
+		})
+	}
+}